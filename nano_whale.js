@@ -29,10 +29,90 @@ const blessed = require('neo-blessed');
 const { exec, spawn, execSync } = require("child_process");
 const util = require("util");
 const os = require("os");
-const execPromise = util.promisify(exec);
+const fs = require("fs");
+const { parseDockerSize, parseStatus } = require("./parsers");
+const { createOperationQueue } = require("./queue");
+const execAsync = util.promisify(exec);
+// `poll` marks the background list refreshes, which run every few seconds: they're left out of the
+// log file, and the raw output panel keeps only the latest run of each
+const execPromise = (cmd, { poll = false, ...opts } = {}) => {
+  if (!poll) writeLogFile("CMD", cmd);
+  const promise = execAsync(cmd, { ...opts, env: dockerEnv() });
+  trackChild(promise.child, cmd);
+  if (state.prefs.rawOutput) {
+    promise.then(
+      ({ stdout, stderr }) => recordRawOutput(cmd, 0, stdout, stderr, poll),
+      error => recordRawOutput(cmd, error.killed ? "killed" : error.code, error.stdout, error.stderr, poll),
+    );
+  }
+  return promise;
+};
+
+// prefs.dockerEnv on top of our own environment; WSLENV forwards the keys into WSL
+function dockerEnv() {
+  const extra = state.prefs.dockerEnv || {};
+  const keys = Object.keys(extra);
+  if (keys.length === 0) return process.env;
+  const wslenv = [process.env.WSLENV, ...keys.map(k => `${k}/u`)].filter(Boolean).join(":");
+  return { ...process.env, ...extra, ...(isWindows ? { WSLENV: wslenv } : {}) };
+}
+
+// In-flight docker processes, so an emergency stop can kill them
+const activeChildren = new Map();
+// Children killed by the emergency stop, so their failure isn't mistaken for a timeout
+const stoppedChildren = new WeakSet();
+
+function trackChild(child, label) {
+  activeChildren.set(child, label);
+  child.on("exit", () => activeChildren.delete(child));
+  child.on("error", () => activeChildren.delete(child));
+}
 
 const isWindows = os.platform() === "win32";
-const dockerCmd = isWindows ? "wsl docker" : "docker";
+const DEFAULT_DOCKER_CMD = isWindows ? "wsl docker" : "docker";
+let dockerCmd = DEFAULT_DOCKER_CMD;
+
+// ==================== PREFERENCES ====================
+// Not ~/.nano-whale: that's the install directory, which the installer wipes on every update
+const PREFS_DIR = isWindows
+  ? path.join(process.env.APPDATA || path.join(os.homedir(), "AppData", "Roaming"), "nano-whale")
+  : path.join(process.env.XDG_CONFIG_HOME || path.join(os.homedir(), ".config"), "nano-whale");
+const PREFS_FILE = path.join(PREFS_DIR, "prefs.json");
+// Where prefs were kept before; read once so they survive until the next save moves them over
+const LEGACY_PREFS_FILE = path.join(os.homedir(), ".nano-whale", "prefs.json");
+
+function loadPrefs() {
+  for (const file of [PREFS_FILE, LEGACY_PREFS_FILE]) {
+    try { return JSON.parse(fs.readFileSync(file, "utf8")); } catch {}
+  }
+  return {};
+}
+
+// Optional on-disk log (prefs.logToFile), rotated to .1 once it exceeds prefs.logMaxKB
+const LOG_FILE = path.join(PREFS_DIR, "nano-whale.log");
+
+function writeLogFile(level, msg) {
+  if (!state.prefs.logToFile) return;
+  try {
+    const maxBytes = (state.prefs.logMaxKB || 1024) * 1024;
+    if (fs.existsSync(LOG_FILE) && fs.statSync(LOG_FILE).size > maxBytes) fs.renameSync(LOG_FILE, `${LOG_FILE}.1`);
+    fs.appendFileSync(LOG_FILE, `${new Date().toISOString()} [${level}] ${msg}\n`);
+  } catch (_) {}
+}
+
+// Warn only once per session so a read-only config dir doesn't spam every toggle
+let prefsWarned = false;
+
+function savePrefs() {
+  try {
+    fs.mkdirSync(PREFS_DIR, { recursive: true });
+    fs.writeFileSync(PREFS_FILE, JSON.stringify(state.prefs, null, 2));
+  } catch (error) {
+    if (prefsWarned) return;
+    prefsWarned = true;
+    notify(`Settings won't persist: cannot write ${PREFS_FILE} (${error.code || error.message})`, "yellow");
+  }
+}
 
 // ==================== STATE ====================
 const state = {
@@ -41,6 +121,7 @@ const state = {
   volumes: [],
   networks: [],
   stats: {},
+  prefs: loadPrefs(),
   env: {},
   config: {},
   top: {},
@@ -56,16 +137,51 @@ const state = {
   currentTab: 0,
   logsContent: "",
   logsAutoScroll: true,
+  logsFilter: null,
+  search: "",
+  sortMode: new Map(),
   inFullscreenMode: false,
+  openDialogs: 0,
+  pendingOps: 0,
+  containerDetails: {},
+  detailsLoading: false,
+  wslWarming: null,
+  wslWarmChild: null,
+  volumeSizes: { sizes: {}, at: 0, loading: false },
+  imageUpdates: new Map(),
+  checkingUpdates: false,
+  restartHistory: {},
+  loopAlerted: new Set(),
+  diskCheckedAt: 0,
+  statsProcs: { name: null, at: 0, rows: [], loading: false, error: null },
+  rootless: false,
+  streamsStopped: false,
+  eventLog: [],
+  rawOutput: [],
+  rawPolls: new Map(),
   statsProcess: null,
   logProcess: null,
   fullscreenChild: null,
   containersInterval: null,
+  containersPolling: false,
+  lastContainersPollAt: 0,
   miscInterval: null,
+  pruneInterval: null,
+  fileWatcher: null,
+  logWindows: new Set(),
+  lastFailure: null,
+  lastPollAt: 0,
+  expectedGone: new Map(),
+  psFailures: 0,
+  backoff: null,
 };
 
+if (state.prefs.dockerCmd) dockerCmd = state.prefs.dockerCmd;
+
 const MAX_HISTORY = 80;
-const TAB_NAMES = ["Logs", "Stats", "Env", "Config", "Top"];
+const MAX_LOG_LINES = 500;
+const STATUS_DRAWER_HEIGHT = 8;
+const TAB_NAMES = ["Logs", "Stats", "Env", "Config", "Top", "Overview"];
 
 // ==================== UI SETUP ====================
 const screen = blessed.screen({
@@ -84,7 +200,7 @@ const ui = {
     top: 0, left: 0, width: "40%", height: 3,
     label: " [1]-Device ", border: { type: "line" },
     style: { border: { fg: "cyan" }, label: { fg: "cyan" } },
-    content: os.hostname(),
+    content: os.hostname(), tags: true,
   }),
   
   containersBox: blessed.list({
@@ -127,6 +243,14 @@ const ui = {
     border: { type: "line" }, style: { border: { fg: "cyan" }, label: { fg: "cyan" } },
     scrollable: true, keys: true, vi: true, mouse: true, tags: true,
     scrollbar: { ch: "│", style: { fg: "cyan" } },
+    wrap: state.prefs.wrapLines !== false,
+  }),
+  
+  statusBox: blessed.box({
+    bottom: 1, left: "40%", width: "60%", height: STATUS_DRAWER_HEIGHT,
+    label: " Status [e] ", border: { type: "line" },
+    style: { border: { fg: "gray" }, label: { fg: "gray" } },
+    tags: true, hidden: true,
   }),
   
   helpBar: blessed.box({
@@ -162,51 +286,109 @@ ui.tabHeader.on('click', async (data) => {
 });
 
 // ==================== DOCKER API ====================
-async function dockerExec(cmd, timeout = 5000) {
+async function dockerExec(cmd, timeout = 5000, { poll = false } = {}) {
+  const run = execPromise(`${dockerCmd} ${cmd}`, { timeout, poll });
   try {
-    const { stdout } = await execPromise(`${dockerCmd} ${cmd}`, { timeout });
+    const { stdout } = await run;
     return stdout.trim();
   } catch (error) {
+    // An idle WSL VM is shut down by Windows; the first call after that times out while it boots.
+    // A command killed by the emergency stop (Ctrl+X) also reports `killed` but must not be re-run
+    if (error.killed && !stoppedChildren.has(run.child) && usesWsl() && await warmWsl()) {
+      try {
+        const { stdout } = await execPromise(`${dockerCmd} ${cmd}`, { timeout, poll });
+        return stdout.trim();
+      } catch (_) {}
+    }
     return null;
   }
 }
 
+// Podman is CLI-compatible for everything used here but has no daemon and a different `info` layout
+function isPodman(cmd = dockerCmd) {
+  return splitCommand(cmd).some(part => /^podman(\.exe)?$/i.test(part));
+}
+
+function usesWsl() {
+  return splitCommand(dockerCmd)[0] === "wsl";
+}
+
+// Boot WSL (or wait for an in-flight boot) with feedback; resolves true once it answers
+function warmWsl() {
+  if (state.wslWarming) return state.wslWarming;
+  notify("Starting WSL...", "yellow");
+  const warm = execPromise("wsl echo ready", { timeout: 60000 });
+  state.wslWarmChild = warm.child;
+  state.wslWarming = warm
+    .then(() => true)
+    .catch(error => {
+      logEvent("ERROR", `WSL did not start: ${error.message}`);
+      logEvent("INFO", "Start it manually with 'wsl echo ready' (and 'sudo service docker start' inside WSL if needed), then press Ctrl+R");
+      return false;
+    })
+    .finally(() => {
+      state.wslWarming = null;
+      state.wslWarmChild = null;
+    });
+  return state.wslWarming;
+}
+
+// "|"-separated --format output to field arrays. Short lines are kept so the callers' defaults fill
+// the gaps; with debug logging on, the first one is logged (once, not on every refresh).
+const lastShortLine = new Map();
+
+function parseRows(kind, out, fieldCount) {
+  const rows = out.split("\n").filter(Boolean).map(line => line.split("|"));
+  const short = rows.filter(fields => fields.length < fieldCount);
+  const first = short[0]?.join("|");
+  if (first && state.prefs.debugLog && lastShortLine.get(kind) !== first) {
+    lastShortLine.set(kind, first);
+    logEvent("DEBUG", `${kind}: ${short.length}/${rows.length} line(s) with fewer than ${fieldCount} fields; first: ${JSON.stringify(first.slice(0, 200))}`);
+  }
+  return rows;
+}
+
 async function getContainers() {
-  const out = await dockerExec('ps -a --format "{{.Names}}|{{.Status}}|{{.ID}}|{{.Image}}|{{.Ports}}|{{.State}}"');
-  if (out === null) return state.containers;
+  const all = state.prefs.hideStopped ? "" : "-a ";
+  // -s makes the daemon measure every writable layer, so it's opt-in
+  const sizes = state.prefs.containerSizes;
+  const out = await dockerExec(`ps ${all}${sizes ? "-s " : ""}--format "{{.Names}}|{{.Status}}|{{.ID}}|{{.Image}}|{{.Ports}}|{{.State}}${sizes ? "|{{.Size}}" : ""}"`, sizes ? 30000 : 5000, { poll: true });
+  if (out === null) {
+    state.psFailures++;
+    return state.containers;
+  }
+  state.psFailures = 0;
   if (!out) return [];
-  return out.split("\n").filter(Boolean).map(line => {
-    const [name, status, id, image, ports, st] = line.split("|");
-    return { name, status, id: id?.substring(0, 12) || "N/A", image, ports: ports || "", state: st || "unknown" };
+  return parseRows("ps", out, sizes ? 7 : 6).map(([name, status, id, image, ports, st, size]) => {
+    const row = { name, status, id: id?.substring(0, 12) || "N/A", image, ports: ports || "", state: st || "unknown" };
+    if (sizes) row.size = size || "";
+    return row;
   });
 }
 
 async function getImages() {
-  const out = await dockerExec('images --format "{{.Repository}}|{{.Tag}}|{{.Size}}|{{.ID}}"');
+  const out = await dockerExec('images --format "{{.Repository}}|{{.Tag}}|{{.Size}}|{{.ID}}"', 5000, { poll: true });
   if (out === null) return state.images;
   if (!out) return [];
-  return out.split("\n").filter(Boolean).map(line => {
-    const [repo, tag, size, id] = line.split("|");
+  return parseRows("images", out, 4).map(([repo, tag, size, id]) => {
     return { repo, tag, size, id: id?.substring(0, 12) || "N/A" };
   });
 }
 
 async function getVolumes() {
-  const out = await dockerExec('volume ls --format "{{.Driver}}|{{.Name}}"');
+  const out = await dockerExec('volume ls --format "{{.Driver}}|{{.Name}}"', 5000, { poll: true });
   if (out === null) return state.volumes;
   if (!out) return [];
-  return out.split("\n").filter(Boolean).map(line => {
-    const [driver, name] = line.split("|");
+  return parseRows("volume ls", out, 2).map(([driver, name]) => {
     return { driver: driver || "local", name: name || "N/A" };
   });
 }
 
 async function getNetworks() {
-  const out = await dockerExec('network ls --format "{{.Driver}}|{{.Name}}"');
+  const out = await dockerExec('network ls --format "{{.Driver}}|{{.Name}}"', 5000, { poll: true });
   if (out === null) return state.networks;
   if (!out) return [];
-  return out.split("\n").filter(Boolean).map(line => {
-    const [driver, name] = line.split("|");
+  return parseRows("network ls", out, 2).map(([driver, name]) => {
     return { driver: driver || "bridge", name: name || "N/A" };
   });
 }
@@ -226,421 +408,2552 @@ async function getContainerInspect(name) {
   try { return JSON.parse(out)[0]; } catch { return null; }
 }
 
+async function getNetworkInspect(name) {
+  const out = await dockerExec(`network inspect ${name}`);
+  try { return JSON.parse(out)[0]; } catch { return null; }
+}
+
+async function getVolumeUsers(name) {
+  const out = await dockerExec(`ps -a --filter volume=${name} --format "{{.Names}}|{{.State}}"`);
+  if (!out) return [];
+  return out.split("\n").filter(Boolean).map(line => {
+    const [cname, st] = line.split("|");
+    return { name: cname, state: st || "unknown" };
+  });
+}
+
+// ==================== OPERATION QUEUE ====================
+// Mutating docker operations run one at a time, in order; reads stay concurrent.
+// A queued operation must never await another queued operation: the inner one is chained behind the
+// outer one, which is waiting for it, so both hang and every later operation queues up behind them.
+// Inside an operation, call dockerExec/execPromise directly; queued helpers (startContainer,
+// createVolume, ...) may only be fired without await, e.g. as retry closures or from key handlers.
+// Refreshes (updateAll, updateContainers, ...) are reads and are safe to await from anywhere.
+const queueOperation = createOperationQueue({
+  onQueued: () => {
+    state.pendingOps++;
+    updateHelpBar();
+  },
+  onError: (label, error, retry) => {
+    logEvent("ERROR", `${label}: ${error.stack || error.message}`);
+    notifyFailure(`${label} failed: ${error.message}`, retry);
+  },
+  onSettled: () => {
+    state.pendingOps--;
+    updateHelpBar();
+    screen.render();
+  },
+});
+
 // ==================== CONTAINER ACTIONS ====================
+// Containers we just deleted (or stopped, which hides them when only running ones are shown), so
+// the refresh doesn't report them as vanished behind our back. Entries expire after a while
+const EXPECTED_GONE_MS = 30000;
+
+function expectGone(name) {
+  state.expectedGone.set(name, Date.now());
+}
+
+function wasExpectedGone(name) {
+  const at = state.expectedGone.get(name);
+  state.expectedGone.forEach((t, n) => Date.now() - t > EXPECTED_GONE_MS && state.expectedGone.delete(n));
+  return at !== undefined && Date.now() - at <= EXPECTED_GONE_MS;
+}
+
 async function startContainer(name) {
-  await dockerExec(`start ${name}`, 30000);
-  notify(`Started ${name}`, "green");
-  await updateAll();
+  return queueOperation(`Start ${name}`, async () => {
+    if (await dockerExec(`start ${name}`, 30000) === null) {
+      notifyFailure(`Failed to start ${name}`, () => startContainer(name));
+      return;
+    }
+    notify(`Started ${name}`, "green");
+    await updateAll();
+  });
 }
 
 async function stopContainer(name) {
-  await dockerExec(`stop ${name}`, 30000);
-  notify(`Stopped ${name}`, "yellow");
-  await updateAll();
+  return queueOperation(`Stop ${name}`, async () => {
+    if (await dockerExec(`stop ${name}`, 30000) === null) {
+      notifyFailure(`Failed to stop ${name}`, () => stopContainer(name));
+      return;
+    }
+    expectGone(name);
+    notify(`Stopped ${name}`, "yellow");
+    await updateAll();
+  });
 }
 
 async function restartContainer(name) {
-  await dockerExec(`restart ${name}`, 60000);
-  notify(`Restarted ${name}`, "green");
-  await updateAll();
+  return queueOperation(`Restart ${name}`, async () => {
+    if (await dockerExec(`restart ${name}`, 60000) === null) {
+      notifyFailure(`Failed to restart ${name}`, () => restartContainer(name));
+      return;
+    }
+    notify(`Restarted ${name}`, "green");
+    await updateAll();
+  });
 }
 
-async function deleteContainer(name) {
-  try {
-    const result = await execPromise(`${dockerCmd} rm -f ${name}`, { timeout: 30000 });
-    notify(`Deleted ${name}`, "red");
-    await updateAll();
-  } catch (error) {
-    notify(`Failed to delete container: ${error.message}`, "red");
+// Without a delay all containers restart together in one queued operation; with one, dependent
+// services are bounced one at a time with a pause in between
+async function restartMany(names, delaySec = null) {
+  if (delaySec === null) {
+    return queueOperation(`Restart ${names.length} containers`, async () => {
+      notify(`Restarting ${names.length} containers...`, "blue");
+      const results = await Promise.all(names.map(name => dockerExec(`restart ${name}`, 60000)));
+      const failed = names.filter((_, i) => results[i] === null);
+      if (failed.length > 0) {
+        notifyFailure(`Failed to restart ${failed.join(", ")}`, () => restartMany(failed));
+      } else {
+        notify(`Restarted ${names.length} containers`, "green");
+      }
+      await updateAll();
+    });
+  }
+  for (const [i, name] of names.entries()) {
+    if (i > 0 && delaySec > 0) await new Promise(resolve => setTimeout(resolve, delaySec * 1000));
+    logEvent("INFO", `Restart ${i + 1}/${names.length}: ${name}`);
+    notify(`Restarting ${i + 1}/${names.length}: ${name}`, "blue");
+    await restartContainer(name);
   }
 }
 
+async function deleteContainer(name) {
+  return queueOperation(`Delete ${name}`, async () => {
+    try {
+      const result = await execPromise(`${dockerCmd} rm -f ${name}`, { timeout: 30000 });
+      expectGone(name);
+      notify(`Deleted ${name}`, "red");
+      await updateAll();
+    } catch (error) {
+      notifyFailure(`Failed to delete container: ${error.message}`, () => deleteContainer(name));
+    }
+  });
+}
+
 async function deleteImage(id) {
-  try {
-    const result = await execPromise(`${dockerCmd} rmi -f ${id}`, { timeout: 30000 });
-    notify(`Deleted image ${id}`, "yellow");
-    await updateImages();
-  } catch (error) {
-    notify(`Failed to delete image: ${error.message}`, "red");
-  }
+  return queueOperation(`Delete image ${id}`, async () => {
+    try {
+      const result = await execPromise(`${dockerCmd} rmi -f ${id}`, { timeout: 30000 });
+      notify(`Deleted image ${id}`, "yellow");
+      await updateImages();
+    } catch (error) {
+      notifyFailure(`Failed to delete image: ${error.message}`, () => deleteImage(id));
+    }
+  });
 }
 
 async function deleteVolume(name) {
+  return queueOperation(`Delete volume ${name}`, async () => {
+    try {
+      const result = await execPromise(`${dockerCmd} volume rm -f ${name}`, { timeout: 30000 });
+      notify(`Deleted volume ${name}`, "magenta");
+      await updateVolumes();
+    } catch (error) {
+      notifyFailure(`Failed to delete volume: ${error.message}`, () => deleteVolume(name));
+    }
+  });
+}
+
+async function deleteNetwork(name) {
+  return queueOperation(`Delete network ${name}`, async () => {
+    try {
+      const result = await execPromise(`${dockerCmd} network rm ${name}`, { timeout: 5000 });
+      notify(`Deleted network ${name}`, "yellow");
+      await updateAll();
+    } catch (error) {
+      notifyFailure(`Failed to delete network: ${error.message}`, () => deleteNetwork(name));
+    }
+  });
+}
+
+// Common signals for `docker kill --signal`; anything else can be typed in
+const SIGNALS = [
+  ["SIGHUP", "reload config (nginx, haproxy, ...)"],
+  ["SIGUSR1", "reopen logs / app-defined"],
+  ["SIGUSR2", "app-defined"],
+  ["SIGTERM", "graceful stop"],
+  ["SIGINT", "interrupt"],
+  ["SIGQUIT", "quit (thread dump in JVMs)"],
+  ["SIGKILL", "force kill"],
+];
+
+// Accepts names with or without the SIG prefix, or signal numbers (1-64)
+function normalizeSignal(input) {
+  const sig = input.trim().toUpperCase();
+  if (/^\d+$/.test(sig)) return +sig >= 1 && +sig <= 64 ? sig : null;
+  if (!/^(SIG)?[A-Z][A-Z0-9+-]*$/.test(sig)) return null;
+  return sig.startsWith("SIG") ? sig : `SIG${sig}`;
+}
+
+function sendSignal(name, signal) {
+  return queueOperation(`Signal ${name}`, async () => {
+    try {
+      await execPromise(`${dockerCmd} kill --signal=${signal} ${name}`, { timeout: 10000 });
+      logEvent("INFO", `Sent ${signal} to ${name}`);
+      notify(`Sent ${signal} to ${name}`, "green");
+      await updateContainers();
+    } catch (error) {
+      const msg = error.stderr?.trim() || error.message;
+      logEvent("ERROR", `Sending ${signal} to ${name}: ${msg}`);
+      notifyFailure(`Failed to send ${signal}: ${msg}`, () => sendSignal(name, signal));
+    }
+  });
+}
+
+function showSendSignal(c) {
+  if (c.state !== "running") return notify(`${c.name} is not running`, "yellow");
+  const items = SIGNALS.map(([sig, desc]) => `${sig.padEnd(8)} {gray-fg}${desc}{/gray-fg}`).concat("Custom...");
+  pickFromList(`Send signal to ${c.name}`, items, idx => {
+    if (idx < SIGNALS.length) return sendSignal(c.name, SIGNALS[idx][0]);
+    promptInput("Signal name or number (e.g. SIGWINCH, USR1, 10):", "", input => {
+      if (!input) return;
+      const signal = normalizeSignal(input);
+      if (!signal) return notify(`Invalid signal: ${input}`, "red");
+      sendSignal(c.name, signal);
+    });
+  });
+}
+
+// `docker export` dumps the container's filesystem (not its image layers/history like `docker save`)
+function exportContainer(name, file) {
+  return queueOperation(`Export ${name}`, async () => {
+    notify(`Exporting ${name}...`, "cyan");
+    const started = Date.now();
+    try {
+      await execPromise(`${dockerCmd} export -o "${toDockerPath(file)}" ${name}`, { timeout: 30 * 60000 });
+      notify(`Exported ${name} to ${file}`, "green");
+      notifyIfLong(started, true, `Exported ${name} to ${file}`);
+    } catch (error) {
+      notifyFailure(`Failed to export: ${error.stderr?.trim() || error.message}`, () => exportContainer(name, file));
+      notifyIfLong(started, false, `Export of ${name} failed`);
+    }
+  });
+}
+
+function showExportContainer(c) {
+  const initial = path.join(process.cwd(), `${c.name}.tar`);
+  promptInput("Export container filesystem (not the image) to tar:", initial, file => {
+    if (!file) return;
+    exportContainer(c.name, path.resolve(file));
+  });
+}
+
+// ==================== DOCKER COMMAND ====================
+// Split a command prefix like `wsl -d "My Distro" docker` into argv, honouring quotes
+function splitCommand(str) {
+  return [...str.matchAll(/"([^"]*)"|'([^']*)'|(\S+)/g)].map(m => m[1] ?? m[2] ?? m[3]);
+}
+
+async function applyDockerCmd(value) {
+  if (splitCommand(value).length === 0) {
+    notify("Docker command can't be empty", "red");
+    return;
+  }
+  
+  const prev = dockerCmd;
+  dockerCmd = value;
   try {
-    const result = await execPromise(`${dockerCmd} volume rm -f ${name}`, { timeout: 30000 });
-    notify(`Deleted volume ${name}`, "magenta");
-    await updateVolumes();
+    await execPromise(`${dockerCmd} --version`, { timeout: 10000 });
   } catch (error) {
-    notify(`Failed to delete volume: ${error.message}`, "red");
+    dockerCmd = prev;
+    notify(`'${value}' is not usable: ${error.stderr?.trim() || error.message}`, "red");
+    return;
   }
+  
+  state.prefs.dockerCmd = value === DEFAULT_DOCKER_CMD ? undefined : value;
+  savePrefs();
+  notify(`Docker command set to: ${value}`, "green");
+  await reconnect();
 }
 
-async function deleteNetwork(name) {
+// Turn a failed docker call into a short, actionable reason
+function classifyDockerError(error) {
+  const text = `${error.stderr || ""} ${error.message || ""}`;
+  if (error.killed || /timed? ?out|deadline exceeded/i.test(text)) return "timed out";
+  if (/no such host|could not resolve|name resolution/i.test(text)) return "DNS lookup failed";
+  if (/permission denied|unauthorized|forbidden|x509|certificate|tls/i.test(text)) return "auth/permission error";
+  if (/cannot connect|connection refused|daemon running/i.test(text)) return "daemon unreachable";
+  return "error";
+}
+
+// Queries the server (not just the client) so remote hosts/contexts are actually reached
+async function checkPrerequisites(cmd = dockerCmd) {
+  const query = isPodman(cmd) ? 'info --format "{{.Version.Version}}"' : 'version --format "{{.Server.Version}}"';
   try {
-    const result = await execPromise(`${dockerCmd} network rm ${name}`, { timeout: 5000 });
-    notify(`Deleted network ${name}`, "yellow");
-    await updateAll();
+    const { stdout } = await execPromise(`${cmd} ${query}`, { timeout: 10000 });
+    return { ok: true, version: stdout.trim() };
   } catch (error) {
-    notify(`Failed to delete network: ${error.message}`, "red");
+    const detail = (error.stderr?.trim() || error.message).split("\n").filter(Boolean).pop();
+    return { ok: false, reason: classifyDockerError(error), detail };
   }
 }
 
-// ==================== STATS STREAMING ====================
-function startStatsStream() {
-  if (state.statsProcess) try { state.statsProcess.kill(); } catch (_) {}
-  
-  const [cmd, ...args] = [...dockerCmd.split(" "), "stats", "--no-stream=false", "--format", "table {{.Name}}\t{{.CPUPerc}}\t{{.MemPerc}}\t{{.MemUsage}}\t{{.NetIO}}\t{{.BlockIO}}\t{{.PIDs}}"];
-  state.statsProcess = spawn(cmd, args, { stdio: ["ignore", "pipe", "pipe"] });
-  
-  let buffer = "";
-  state.statsProcess.stdout.on("data", chunk => {
-    buffer += chunk.toString();
-    const lines = buffer.split("\n");
-    buffer = lines.pop();
-    
-    lines.forEach(line => {
-      if (!line.trim() || line.startsWith("NAME")) return;
-      const parts = line.split(/\s{2,}|\t/);
-      if (parts.length < 7) return;
-      
-      const [name, cpuStr, memStr, memUsage, netIO, blockIO, pids] = parts;
-      if (!name) return;
-      
-      const cpu = parseFloat(cpuStr?.replace("%", "")) || 0;
-      const mem = parseFloat(memStr?.replace("%", "")) || 0;
-      
-      state.stats[name] = { cpu, mem, memUsage: memUsage || "N/A", netIO: netIO || "N/A", blockIO: blockIO || "N/A", pids: pids || "N/A" };
-      
-      if (!state.cpuHistory[name]) state.cpuHistory[name] = [];
-      if (!state.memHistory[name]) state.memHistory[name] = [];
-      
-      state.cpuHistory[name].push(cpu);
-      state.memHistory[name].push(mem);
-      
-      if (state.cpuHistory[name].length > MAX_HISTORY) state.cpuHistory[name].shift();
-      if (state.memHistory[name].length > MAX_HISTORY) state.memHistory[name].shift();
+// Rootless daemons are user-managed (systemctl --user), so flag them in the Device box
+async function detectRootless() {
+  const query = isPodman() ? "{{.Host.Security.Rootless}}" : "{{json .SecurityOptions}}";
+  const out = await dockerExec(`info --format "${query}"`, 10000);
+  state.rootless = /rootless|true/.test(out || "");
+  updateDeviceBox();
+}
+
+function updateDeviceBox() {
+  const profile = state.prefs.activeProfile ? ` {cyan-fg}${state.prefs.activeProfile}{/cyan-fg}` : "";
+  const backoff = state.backoff ? ` {red-fg}reconnecting in ${Math.round(state.backoff.delay / 1000)}s...{/red-fg}` : "";
+  ui.projectBox.setContent(os.hostname() + profile + (state.rootless ? " {yellow-fg}rootless{/yellow-fg}" : "") + backoff);
+  screen.render();
+}
+
+// ==================== PREREQUISITES ====================
+const CHECK_ICONS = {
+  pending: "{yellow-fg}⏳{/yellow-fg}",
+  ok: "{green-fg}✓{/green-fg}",
+  fail: "{red-fg}✗{/red-fg}",
+  skip: "{gray-fg}-{/gray-fg}",
+};
+const INSTALL_URL = "https://docs.docker.com/get-docker/";
+
+// How to start the daemon behind the current command; null for remote hosts and daemonless podman
+function daemonStartCommand() {
+  const parts = splitCommand(dockerCmd);
+  if (/(^|\s)(-H|--host|--context|-c)(\s|=)/.test(dockerCmd)) return null;
+  if (parts[0] === "wsl") {
+    const prefix = parts.slice(0, -1).map(p => /\s/.test(p) ? `"${p}"` : p).join(" ");
+    return `${prefix} sudo service docker start`;
+  }
+  if (isPodman()) return os.platform() === "linux" ? null : "podman machine start";
+  if (os.platform() === "darwin") return "open -a Docker";
+  if (os.platform() === "win32") return '"%ProgramFiles%\\Docker\\Docker\\Docker Desktop.exe"';
+  return state.rootless ? "systemctl --user start docker" : "sudo systemctl start docker";
+}
+
+// Each check resolves { ok, detail }; later checks are skipped once one fails
+function prerequisiteChecks() {
+  const lastLine = error => (error.stderr?.trim() || error.message).split("\n").filter(Boolean).pop();
+  const checks = [];
+  if (usesWsl()) {
+    checks.push({
+      name: "WSL",
+      run: async () => await warmWsl() ? { ok: true, detail: "running" } : { ok: false, detail: "did not start; details in the event log (Shift+E)" },
     });
-    
-    if (!state.inFullscreenMode && state.currentTab === 1) updateStatsTab();
+  }
+  checks.push({
+    name: "Docker CLI",
+    run: async () => {
+      try {
+        const { stdout } = await execPromise(`${dockerCmd} --version`, { timeout: 10000 });
+        return { ok: true, detail: stdout.trim() };
+      } catch (error) {
+        return { ok: false, detail: lastLine(error) };
+      }
+    },
+    fix: { label: "copy install guide URL", run: () => copyToClipboard(INSTALL_URL) },
   });
-  
-  state.statsProcess.on("close", () => {
-    setTimeout(() => {
-      if (!state.inFullscreenMode && (!state.statsProcess || state.statsProcess.killed)) startStatsStream();
-    }, 2000);
+  const start = daemonStartCommand();
+  checks.push({
+    name: "Daemon",
+    run: async () => {
+      const res = await checkPrerequisites();
+      return res.ok ? { ok: true, detail: `server ${res.version}` } : { ok: false, detail: `${res.reason}: ${res.detail}` };
+    },
+    // In a terminal window, so sudo can ask for a password
+    fix: start && { label: `start it (${start})`, run: () => spawnNewWindow(start, "start-docker") },
   });
+  return checks;
 }
 
-// ==================== LOGS ====================
-function showContainerLogs(name, tail = "10") {
-  if (!name || state.inFullscreenMode) return;
-  stopLogStream();
-  
-  state.logsContent = "";
-  const [cmd, ...args] = [...dockerCmd.split(" "), "logs", "-f", "--tail", tail, name];
-  state.logProcess = spawn(cmd, args, { stdio: ['ignore', 'pipe', 'pipe'] });
+// onClose(passed) runs when the dialog is closed, with whether the last run passed every check
+function showPrerequisites(onClose = () => {}) {
+  const checks = prerequisiteChecks();
+  const results = checks.map(() => ({ status: "pending", detail: "" }));
+  const dialog = showDialog("Prerequisites", "");
+  let closed = false;
+  let running = false;
+  dialog.on("destroy", () => {
+    closed = true;
+    setImmediate(() => onClose(!running && results.every(r => r.status === "ok")));
+  });
   
-  const onData = data => {
-    if (state.inFullscreenMode) return;
-    state.logsContent += data.toString();
-    if (state.logsContent.length > 100000) state.logsContent = state.logsContent.slice(-100000);
-    if (state.currentTab === 0) {
-      ui.contentBox.setContent(state.logsContent);
-      if (state.logsAutoScroll) ui.contentBox.setScrollPerc(100);
-      screen.render();
+  const fixable = () => checks.find((check, i) => results[i].status === "fail" && check.fix);
+  const render = () => {
+    if (closed) return;
+    const rows = checks.map((check, i) => `${CHECK_ICONS[results[i].status]} {bold}${check.name.padEnd(12)}{/bold} ${blessed.escape(results[i].detail)}`);
+    const passed = !running && results.every(r => r.status === "ok");
+    const keys = [fixable() && `[f] ${fixable().fix.label}`, !running && "[r] re-check", "[Esc] close"].filter(Boolean);
+    const hint = passed ? "\n{green-fg}All checks passed{/green-fg}" : "";
+    dialog.setContent(`${rows.join("\n")}\n${hint}\n{gray-fg}${blessed.escape(keys.join("  "))}{/gray-fg}`);
+    screen.render();
+  };
+  const runAll = async () => {
+    if (running) return;
+    running = true;
+    results.forEach(r => Object.assign(r, { status: "pending", detail: "" }));
+    render();
+    let failed = false;
+    for (const [i, check] of checks.entries()) {
+      if (failed) {
+        Object.assign(results[i], { status: "skip", detail: "skipped" });
+        continue;
+      }
+      const { ok, detail } = await check.run();
+      Object.assign(results[i], { status: ok ? "ok" : "fail", detail });
+      logEvent(ok ? "INFO" : "ERROR", `Prerequisite ${check.name}: ${detail}`);
+      failed = !ok;
+      render();
     }
+    running = false;
+    render();
   };
   
-  state.logProcess.stdout.on("data", onData);
-  state.logProcess.stderr.on("data", onData);
+  dialog.key(["f"], () => !running && fixable()?.fix.run());
+  dialog.key(["r"], runAll);
+  runAll();
 }
 
-function stopLogStream() {
-  if (state.logProcess) {
-    try {
-      if (state.logProcess.stdout) state.logProcess.stdout.destroy();
-      if (state.logProcess.stderr) state.logProcess.stderr.destroy();
-      state.logProcess.kill('SIGKILL');
-    } catch (_) {}
-    state.logProcess = null;
-  }
-}
+// ==================== DOCKER INFO ====================
+// Sections of `docker info --format "{{json .}}"`; values are formatters over the parsed object
+const INFO_SECTIONS = [
+  ["Server", [
+    ["Version", i => i.ServerVersion],
+    ["Name", i => i.Name],
+    ["Operating system", i => i.OperatingSystem],
+    ["Kernel", i => i.KernelVersion],
+    ["Architecture", i => i.Architecture],
+    ["CPUs", i => i.NCPU],
+    ["Memory", i => i.MemTotal && humanBytes(i.MemTotal)],
+  ]],
+  ["Objects", [
+    ["Containers", i => `${i.Containers} (${i.ContainersRunning} running, ${i.ContainersPaused} paused, ${i.ContainersStopped} stopped)`],
+    ["Images", i => i.Images],
+  ]],
+  ["Storage", [
+    ["Driver", i => i.Driver],
+    ["Root dir", i => i.DockerRootDir],
+    ["Driver status", i => (i.DriverStatus || []).map(([k, v]) => `${k}: ${v}`).join(", ")],
+  ]],
+  ["Runtime", [
+    ["Cgroup", i => i.CgroupVersion && `v${i.CgroupVersion} (${i.CgroupDriver})`],
+    ["Default runtime", i => i.DefaultRuntime],
+    ["Runtimes", i => Object.keys(i.Runtimes || {}).join(", ")],
+    ["Security", i => (i.SecurityOptions || []).map(o => o.replace(/^name=/, "").split(",")[0]).join(", ")],
+    ["Logging driver", i => i.LoggingDriver],
+    ["Live restore", i => i.LiveRestoreEnabled],
+  ]],
+  ["Network", [
+    ["HTTP proxy", i => i.HttpProxy],
+    ["HTTPS proxy", i => i.HttpsProxy],
+    ["No proxy", i => i.NoProxy],
+    ["Registry", i => i.IndexServerAddress],
+    ["Mirrors", i => (i.RegistryConfig?.Mirrors || []).join(", ")],
+    ["Swarm", i => i.Swarm?.LocalNodeState],
+  ]],
+];
 
-// ==================== CHARTS ====================
-function smoothChart(data, height = 12, width = 60, color = "cyan", label = "") {
-  if (!data || data.length < 2) {
-    return Array(height).fill(" ".repeat(width)).join("\n") + `\n{${color}-fg}        ${label} 0.00 % (waiting…){/${color}-fg}`;
+async function showDockerInfo() {
+  const out = await dockerExec('info --format "{{json .}}"', 15000);
+  let info;
+  try { info = JSON.parse(out); } catch (_) {
+    return notify("Failed to read docker info", "red");
   }
   
-  const slice = data.slice(-width);
-  const max = Math.max(...slice);
-  const min = Math.min(...slice);
-  const range = max - min || 1;
-  
-  const dots = [[0x01, 0x08], [0x02, 0x10], [0x04, 0x20], [0x40, 0x80]];
-  const pxW = width * 2, pxH = height * 4;
-  const canvas = Array.from({ length: pxH }, () => Array(pxW).fill(0));
-  const y = v => Math.round(pxH - 1 - ((v - min) / range) * (pxH - 1));
-  
-  for (let i = 0; i < slice.length - 1; i++) {
-    const x0 = Math.round((i / (slice.length - 1)) * (pxW - 1));
-    const x1 = Math.round(((i + 1) / (slice.length - 1)) * (pxW - 1));
-    const y0 = y(slice[i]), y1 = y(slice[i + 1]);
-    const steps = Math.max(Math.abs(x1 - x0), Math.abs(y1 - y0)) * 2;
-    
-    for (let s = 0; s <= steps; s++) {
-      const t = s / steps;
-      const sx = Math.round(x0 + (x1 - x0) * t);
-      const sy = Math.round(y0 + (y1 - y0) * t);
-      for (let dy = 0; dy <= 1; dy++) {
-        for (let dx = 0; dx <= 1; dx++) {
-          const cx = sx + dx, cy = sy + dy;
-          if (cx >= 0 && cx < pxW && cy >= 0 && cy < pxH) canvas[cy][cx] = 1;
-        }
+  let text = "";
+  const structured = info.ServerVersion !== undefined;
+  if (!structured) {
+    // podman's info has a different shape; show it as-is
+    text = JSON.stringify(info, null, 2);
+  } else {
+    for (const [section, fields] of INFO_SECTIONS) {
+      text += `${section}\n`;
+      for (const [label, get] of fields) {
+        const value = get(info);
+        if (value !== undefined && value !== "") text += `  ${label.padEnd(17)} ${value}\n`;
       }
+      text += "\n";
     }
+    if (info.Warnings?.length) text += `Warnings\n${info.Warnings.map(w => `  ${w}`).join("\n")}\n`;
   }
   
-  const rows = [];
-  for (let row = 0; row < pxH; row += 4) {
-    let line = "";
-    for (let col = 0; col < pxW; col += 2) {
-      let code = 0x2800;
-      for (let dy = 0; dy < 4; dy++) {
-        for (let dx = 0; dx < 2; dx++) {
-          if (canvas[row + dy]?.[col + dx]) code |= dots[dy][dx];
-        }
-      }
-      line += String.fromCharCode(code);
-    }
-    const val = max - (row / 4 / (height - 1)) * range;
-    rows.push(`${val.toFixed(2).padStart(6)} │{${color}-fg}${line}{/${color}-fg}`);
+  let content = blessed.escape(text.trimEnd());
+  if (structured) content = content.replace(/^(\S.*)$/gm, "{bold}{yellow-fg}$1{/yellow-fg}{/bold}");
+  const dialog = showDialog("Docker Info", `{gray-fg}[c] copy{/gray-fg}\n\n${content}`);
+  dialog.key(["c"], () => copyToClipboard(text));
+}
+
+// ==================== PROFILES ====================
+// Named docker command prefixes, e.g. "Remote Prod" = "docker --context prod"
+async function switchProfile(profile) {
+  notify(`Checking ${profile.name}...`, "cyan");
+  const res = await checkPrerequisites(profile.cmd);
+  if (!res.ok) {
+    notify(`${profile.name} unavailable (${res.reason}): ${res.detail}`, "red");
+    return;
   }
-  
-  rows.push("       └" + "─".repeat(width));
-  const cur = slice[slice.length - 1];
-  rows.push(`\n{${color}-fg}        ${label} ${cur.toFixed(2)} %  (${slice.length * 2}s){/${color}-fg}`);
-  return rows.join("\n");
+  state.prefs.activeProfile = profile.name;
+  await applyDockerCmd(profile.cmd);
+  updateDeviceBox();
 }
 
-function humanBytes(n) {
-  const units = ["B", "kB", "MB", "GB", "TB"];
-  let i = 0;
-  while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
-  return `${n.toFixed(1)}${units[i]}`;
+function saveProfile(done) {
+  promptInput("Profile name:", "", name => {
+    if (!name) return done();
+    promptInput(`Docker command for ${name}:`, dockerCmd, cmd => {
+      if (splitCommand(cmd).length === 0) return done();
+      const profiles = (state.prefs.profiles || []).filter(p => p.name !== name);
+      state.prefs.profiles = [...profiles, { name, cmd }];
+      notify(`Saved profile ${name}`, "green");
+      done();
+    });
+  });
 }
 
-// ==================== UI UPDATES ====================
-function updateTabHeader() {
-  let header = "";
-  TAB_NAMES.forEach((name, i) => {
-    header += i === state.currentTab ? `{cyan-fg}{bold} ${name} {/bold}{/cyan-fg}` : `{gray-fg} ${name} {/gray-fg}`;
-    if (i < TAB_NAMES.length - 1) header += "{white-fg}-{/white-fg}";
+function showProfiles(done = () => {}) {
+  const profiles = state.prefs.profiles || [];
+  const items = profiles.map(p => `${p.name === state.prefs.activeProfile ? "{green-fg}●{/green-fg}" : " "} ${p.name.padEnd(20)} {gray-fg}${p.cmd}{/gray-fg}`);
+  items.push("{cyan-fg}+ Save a profile...{/cyan-fg}");
+  if (profiles.length) items.push("{red-fg}- Delete a profile...{/red-fg}");
+  
+  pickFromList("Profiles", items, async idx => {
+    if (idx < profiles.length) {
+      await switchProfile(profiles[idx]);
+      done();
+    } else if (idx === profiles.length) {
+      saveProfile(done);
+    } else {
+      pickFromList("Delete profile", profiles.map(p => p.name), del => {
+        const name = profiles[del].name;
+        state.prefs.profiles = profiles.filter((_, i) => i !== del);
+        if (state.prefs.activeProfile === name) state.prefs.activeProfile = undefined;
+        updateDeviceBox();
+        notify(`Deleted profile ${name}`, "yellow");
+        done();
+      });
+    }
   });
-  ui.tabHeader.setContent(header);
 }
 
-function updateHelpBar() {
-  ui.helpBar.setContent("{bold}q{/}:Quit {bold}←→{/}:Tabs {bold}↑↓{/}:Nav {bold}s{/}:Start/Stop {bold}r{/}:Restart {bold}t{/}:Exec {bold}d{/}:Delete {bold}m{/}:Mark {bold}C-a{/}:SelectAll {bold}l{/}:Logs {bold}a{/}:AutoScroll {bold}F5{/}:Refresh");
+// Try a prefix such as `docker -H ssh://host` or `docker --context prod` without applying it
+async function testDockerCmd(cmd) {
+  notify(`Testing ${cmd}...`, "cyan");
+  const started = Date.now();
+  const res = await checkPrerequisites(cmd);
+  const ms = Date.now() - started;
+  if (res.ok) notify(`OK: server ${res.version} (${ms}ms)`, "green");
+  else notify(`Failed (${res.reason}, ${ms}ms): ${res.detail}`, "red");
 }
 
-function updateListIfChanged(list, newData, formatFn, indexRef) {
-  if (!newData || newData.length === 0) {
-    const def = ["{yellow-fg}No items{/yellow-fg}"];
-    if (list.items.length !== 1 || list.items[0].content !== def[0]) {
-      list.setItems(def);
-      list.select(0);
-      screen.render();
-    }
-    indexRef[0] = 0;
-    return;
-  }
+// Soft reset: drop every stream and cached row from the previous backend, then start over
+async function reconnect() {
+  notify("Reconnecting...", "yellow");
+  stopLogStream();
+  stopFileWatcher();
+  state.streamsStopped = true;
+  if (state.statsProcess) try { state.statsProcess.kill("SIGKILL"); } catch (_) {}
+  state.statsProcess = null;
   
-  const newItems = newData.map(formatFn);
-  const hasChanged = list.items.length !== newItems.length || list.items.some((item, i) => item.content !== newItems[i]);
+  Object.assign(state, {
+    containers: [], images: [], volumes: [], networks: [],
+    stats: {}, cpuHistory: {}, memHistory: {}, containerDetails: {}, logsContent: "",
+  });
+  [state.markedContainers, state.markedImages, state.markedVolumes].forEach(set => set.clear());
+  [ui.containersBox, ui.imagesBox, ui.volumesBox, ui.networksBox].forEach(list => list.setItems([]));
+  ui.contentBox.setContent("{yellow-fg}Connecting...{/yellow-fg}");
+  screen.render();
   
-  if (hasChanged) {
-    const wasFocused = screen.focused === list;
-    const cur = list.selected;
-    list.setItems(newItems);
-    const idx = Math.min(cur, newItems.length - 1);
-    list.select(Math.max(0, idx));
-    if (wasFocused) list.focus();
-    screen.render();
-    indexRef[0] = Math.max(0, idx);
-  } else {
-    indexRef[0] = list.selected;
+  const res = await checkPrerequisites();
+  if (!res.ok) {
+    ui.contentBox.setContent(`{red-fg}Can't reach docker (${res.reason}): ${blessed.escape(res.detail || "")}{/red-fg}\n\nPress Ctrl+R to retry.`);
+    notify(`Can't reach docker (${res.reason}): ${res.detail}`, "red");
+    return;
   }
+  stopBackoff();
+  startStatsStream();
+  await updateAll();
+  await detectRootless();
+  notify(`Connected to docker ${res.version}`, "green");
 }
 
-async function updateContainers() {
-  try {
-    state.containers = await getContainers();
-    const fmt = c => {
-      const st = state.stats[c.name] || { cpu: 0, mem: 0 };
-      const running = c.state === "running";
-      const paused = c.status.includes("Paused");
-      let status = running ? (paused ? "{yellow-fg}paused{/yellow-fg}" : "{green-fg}running{/green-fg}") : "{red-fg}exited{/red-fg}";
-      if (c.status.includes("healthy")) status = "{green-fg}running (healthy){/green-fg}";
-      const mark = state.markedContainers.has(c.name) ? "{white-bg}{black-fg}[✓]{/black-fg}{/white-bg} " : "    ";
-      const name = c.name.substring(0, 18).padEnd(18);
-      const cpu = running ? `${st.cpu.toFixed(2)}%`.padStart(7) : "      -";
-      const ports = c.ports?.substring(0, 12) || "";
-      return `${mark}${status.padEnd(25)} {bold}${name}{/bold} ${cpu} {cyan-fg}${ports}{/cyan-fg}`;
-    };
-    updateListIfChanged(ui.containersBox, state.containers, fmt, [state.selectedContainerIndex]);
-    state.selectedContainerIndex = ui.containersBox.selected;
-    updateHelpBar();
-  } catch (err) {
-    ui.containersBox.setItems([`{red-fg}Error: ${err.message}{/red-fg}`]);
+// Polling found the daemon gone: retry the server check at 2s, 4s, 8s, ... up to a minute apart,
+// and reconnect once it answers. Polling pauses meanwhile
+const BACKOFF_START_MS = 2000;
+const BACKOFF_MAX_MS = 60000;
+
+function startBackoff() {
+  if (state.backoff) return;
+  state.backoff = { delay: BACKOFF_START_MS, timer: null };
+  logEvent("WARN", "Docker daemon unreachable; retrying with backoff");
+  notify("Docker unreachable, reconnecting...", "red");
+  const attempt = async () => {
+    if (!state.backoff) return;
+    const res = await checkPrerequisites();
+    if (!state.backoff) return;
+    if (res.ok) {
+      logEvent("INFO", `Docker daemon is back (${res.version})`);
+      await reconnect();
+      // Still set when reconnect's own check lost a race with another bounce
+      if (state.backoff) state.backoff.timer = setTimeout(attempt, state.backoff.delay);
+      return;
+    }
+    state.backoff.delay = Math.min(state.backoff.delay * 2, BACKOFF_MAX_MS);
+    state.backoff.timer = setTimeout(attempt, state.backoff.delay);
+    updateDeviceBox();
+  };
+  state.backoff.timer = setTimeout(attempt, state.backoff.delay);
+  updateDeviceBox();
+}
+
+function stopBackoff() {
+  if (!state.backoff) return;
+  clearTimeout(state.backoff.timer);
+  state.backoff = null;
+  state.psFailures = 0;
+  updateDeviceBox();
+}
+
+// ==================== SHELL ====================
+// Preferred shell first (NANO_WHALE_SHELL), then bash, ash (alpine), sh
+function shellChain() {
+  return [process.env.NANO_WHALE_SHELL, "/bin/bash", "/bin/ash", "/bin/sh"].filter(Boolean);
+}
+
+function shellCommand(name, { user, workdir } = {}) {
+  const tries = shellChain().map(sh => `[ -x ${sh} ] && exec ${sh}`).join("; ");
+  const flags = `${user ? `-u ${user} ` : ""}${workdir ? `-w ${workdir} ` : ""}`;
+  return `${dockerCmd} exec -it ${flags}${name} sh -c "${tries}; echo No usable shell found; exit 127"`;
+}
+
+// Blank fields keep docker's defaults (the image's USER and WORKDIR)
+function showExecAs(c) {
+  if (!c || c.state !== "running") {
+    notify("Container must be running", "red");
+    return;
   }
+  promptInput("Exec as user (name, uid or uid:gid; blank = image default):", "", user => {
+    if (user && !/^[\w.-]+(:[\w.-]+)?$/.test(user)) {
+      notify(`Invalid user: ${user}`, "red");
+      return;
+    }
+    promptInput("Working directory (absolute path; blank = image default):", "", workdir => {
+      if (workdir && !/^\/[^\s"'`$;&|]*$/.test(workdir)) {
+        notify(`Invalid working directory: ${workdir}`, "red");
+        return;
+      }
+      execShell(c, { user, workdir });
+    });
+  });
 }
 
-async function updateImages(force = false) {
-  try {
-    const imgs = await getImages();
-    if (!force && JSON.stringify(imgs) === JSON.stringify(state.images)) return;
-    state.images = imgs;
-    const fmt = img => {
-      const mark = state.markedImages.has(img.id) ? "{white-bg}{black-fg}[✓]{/black-fg}{/white-bg} " : "    ";
-      return `${mark}${img.repo.substring(0, 20).padEnd(20)} {yellow-fg}${img.tag.substring(0, 10).padEnd(10)}{/yellow-fg} ${img.size.padEnd(10)}`;
-    };
-    updateListIfChanged(ui.imagesBox, state.images, fmt, [state.selectedImageIndex]);
-    state.selectedImageIndex = ui.imagesBox.selected;
-  } catch { ui.imagesBox.setItems(["{red-fg}Error{/red-fg}"]); }
+// ==================== IMAGE ACTIONS ====================
+function imageLabel(id) {
+  const img = state.images.find(i => i.id === id);
+  return img ? `${img.repo}:${img.tag} (${id})` : id;
 }
 
-async function updateVolumes(force = false) {
-  try {
-    const vols = await getVolumes();
-    if (!force && JSON.stringify(vols) === JSON.stringify(state.volumes)) return;
-    state.volumes = vols;
-    const fmt = v => {
-      const mark = state.markedVolumes.has(v.name) ? "{white-bg}{black-fg}[✓]{/black-fg}{/white-bg} " : "    ";
-      return `${mark}{magenta-fg}${v.driver.padEnd(8)}{/magenta-fg} ${v.name}`;
-    };
-    updateListIfChanged(ui.volumesBox, state.volumes, fmt, [state.selectedVolumeIndex]);
-    state.selectedVolumeIndex = ui.volumesBox.selected;
-  } catch { ui.volumesBox.setItems(["{red-fg}Error{/red-fg}"]); }
+function imageRef(img) {
+  if (img.repo === "<none>") return img.id;
+  return img.tag && img.tag !== "<none>" ? `${img.repo}:${img.tag}` : img.repo;
 }
 
-async function updateNetworks() {
-  try {
-    const nets = await getNetworks();
-    if (JSON.stringify(nets) === JSON.stringify(state.networks)) return;
-    state.networks = nets;
-    const sys = ['bridge', 'host', 'none'];
-    const fmt = n => sys.includes(n.name) ? `{gray-fg}${n.driver.padEnd(8)} ${n.name} (system){/gray-fg}` : `{blue-fg}${n.driver.padEnd(8)}{/blue-fg} ${n.name}`;
-    updateListIfChanged(ui.networksBox, state.networks, fmt, [state.selectedNetworkIndex]);
-    state.selectedNetworkIndex = ui.networksBox.selected;
-  } catch { ui.networksBox.setItems(["{red-fg}Error{/red-fg}"]); }
+// Throwaway container (--rm) in a new terminal window
+function runImageInteractive(img) {
+  const ref = imageRef(img);
+  promptInput(`Command to run in ${ref}:`, "sh", command => {
+    if (!command) return;
+    spawnNewWindow(`${dockerCmd} run -it --rm ${ref} ${command}`, `run-${ref}`);
+  });
 }
 
-async function updateAll() {
-  state.env = {};
-  state.config = {};
-  state.top = {};
-  await Promise.all([updateContainers(), updateImages(), updateVolumes(), updateNetworks()]);
-  await updateCurrentTab();
-  screen.render();
+function runImageDetached(img) {
+  const ref = imageRef(img);
+  promptInput(`Options for 'run -d ${ref}' (e.g. -p 8080:80 --name web):`, "", opts => chooseRunMode(ref, opts, "Run", checkPortsAndRun));
 }
 
-// ==================== TAB CONTENT ====================
-function updateLogsTab() {
-  const c = state.containers[state.selectedContainerIndex];
-  ui.contentBox.setContent(c ? (state.logsContent || "{gray-fg}No logs yet...{/gray-fg}") : "{yellow-fg}No container selected{/yellow-fg}");
-  screen.render();
+// Same options as run, but the container is left in "Created" state to be started later
+function createFromImage(img) {
+  const ref = imageRef(img);
+  promptInput(`Options for 'create ${ref}' (e.g. -p 8080:80 --name web):`, "", opts => chooseRunMode(ref, opts, "Create", createContainer));
 }
 
-function updateStatsTab() {
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c) {
-    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
-    screen.render();
+function chooseRunMode(ref, opts, verb, start) {
+  const items = /(^|\s)--rm(\s|$)/.test(opts)
+    ? [verb]
+    : [`${verb}, keep it when it exits (can be restarted, inspected)`, `${verb}, remove it when it exits (--rm)`];
+  items.push("+ New volume...");
+  pickFromList(opts ? `${verb.toLowerCase()} ${opts}` : "When the container exits", items, idx => {
+    if (idx === items.length - 1) return addNewVolumeMount(opts, next => chooseRunMode(ref, next, verb, start));
+    start(ref, idx === 1 ? `--rm ${opts}`.trim() : opts);
+  });
+}
+
+function createContainer(ref, opts) {
+  let id = "";
+  checkDiskSpace();
+  queueOperation(`Create from ${ref}`, async () => {
+    try {
+      const { stdout } = await execPromise(`${dockerCmd} create ${opts} ${ref}`, { timeout: 120000 });
+      id = stdout.trim().split("\n").pop();
+      notify(`Created ${id.slice(0, 12)} from ${ref}; start it with s`, "green");
+    } catch (error) {
+      notifyFailure(`Failed to create: ${error.stderr?.trim() || error.message}`, () => createContainer(ref, opts));
+    }
+  }).then(() => id && selectNewContainer(id));
+}
+
+async function selectNewContainer(id) {
+  await updateContainers();
+  const idx = state.containers.findIndex(c => id.startsWith(c.id));
+  if (idx === -1) {
+    if (state.prefs.hideStopped) notify("Created containers are hidden; press v to show stopped ones", "yellow");
     return;
   }
+  ui.containersBox.focus();
+  ui.containersBox.select(idx);
+  state.selectedContainerIndex = idx;
+  await updateCurrentTab();
+  screen.render();
+}
+
+// Creates a named volume and appends `-v name:/path` to the run options
+function addNewVolumeMount(opts, done) {
+  promptInput("New volume name:", "", name => {
+    if (!name) return done(opts);
+    if (!isValidVolumeName(name)) {
+      notify(`Invalid volume name: ${name}`, "red");
+      return done(opts);
+    }
+    promptInput(`Mount ${name} at (absolute path in the container):`, "/data", mountPath => {
+      if (!mountPath) return done(opts);
+      if (!/^\/[^\s:"'`$;&|]+$/.test(mountPath) || mountPath === "/") {
+        notify(`Invalid mount path: ${mountPath}`, "red");
+        return done(opts);
+      }
+      createVolume(name);
+      done(`${opts} -v ${name}:${mountPath}`.trim());
+    });
+  });
+}
+
+async function checkPortsAndRun(ref, opts) {
+  const conflicts = await findPortConflicts(opts);
+  if (conflicts.length === 0) return startDetached(ref, opts);
   
-  const st = state.stats[c.name] || {};
-  const running = c.state === "running";
+  const used = conflicts.map(c => `${c.port} (${c.owner})`).join(", ");
+  pickFromList(`Host port in use: ${used}`, [
+    `Use ${conflicts.map(c => c.free).join(", ")} instead`,
+    "Run anyway",
+    "Cancel",
+  ], idx => {
+    if (idx === 0) {
+      const fixed = conflicts.reduce((acc, c) => acc.replace(c.spec, c.spec.replace(`${c.port}:`, `${c.free}:`)), opts);
+      startDetached(ref, fixed);
+    } else if (idx === 1) {
+      startDetached(ref, opts);
+    }
+  });
+}
+
+function startDetached(ref, opts) {
+  let id = "";
+  checkDiskSpace();
+  queueOperation(`Run ${ref}`, async () => {
+    try {
+      const { stdout } = await execPromise(`${dockerCmd} run -d ${opts} ${ref}`, { timeout: 120000 });
+      id = stdout.trim().split("\n").pop();
+      notify(`Started ${ref} (${id.slice(0, 12)})`, "green");
+    } catch (error) {
+      notifyFailure(`Failed to run: ${error.stderr?.trim() || error.message}`, () => startDetached(ref, opts));
+    }
+  }).then(() => id && state.prefs.followAfterRun !== false && followNewContainer(id));
+}
+
+// ==================== TEMPLATES ====================
+// Saved run settings: { name, image, options, namePattern }; "{n}" in the pattern
+// becomes the lowest number not already used by a container
+function templateContainerName(pattern) {
+  if (!pattern) return "";
+  if (!pattern.includes("{n}")) return pattern;
+  const taken = new Set(state.containers.map(c => c.name));
+  let n = 1;
+  while (taken.has(pattern.replace("{n}", n))) n++;
+  return pattern.replace("{n}", n);
+}
+
+function runTemplate(t) {
+  const name = templateContainerName(t.namePattern);
+  checkPortsAndRun(t.image, `${name ? `--name ${name} ` : ""}${t.options || ""}`.trim());
+}
+
+function editTemplate(existing, done) {
+  const t = { ...(existing || { name: "", image: "", options: "", namePattern: "" }) };
+  promptInput("Template name:", t.name, name => {
+    if (!name) return;
+    promptImageRef("Image", t.image, image => {
+      if (!image) return;
+      if (!isValidImageRef(image)) return notify(`Invalid image reference: ${image}`, "red");
+      promptInput("Run options (ports, env, volumes, e.g. -p 8080:80 -e KEY=v -v data:/data):", t.options, options => {
+        promptInput("Container name (optional; {n} auto-increments, e.g. dev-{n}):", t.namePattern, namePattern => {
+          const templates = (state.prefs.templates || []).filter(x => x.name !== name && x.name !== existing?.name);
+          state.prefs.templates = [...templates, { name, image, options, namePattern }];
+          savePrefs();
+          notify(`Saved template ${name}`, "green");
+          done();
+        });
+      });
+    });
+  });
+}
+
+function showTemplates() {
+  const templates = state.prefs.templates || [];
+  const items = templates.map(t => `${t.name.padEnd(20)} {gray-fg}${t.image} ${t.options || ""}{/gray-fg}`);
+  items.push("{cyan-fg}+ New template...{/cyan-fg}");
   
-  if (!state.cpuHistory[c.name]) state.cpuHistory[c.name] = [0];
-  if (!state.memHistory[c.name]) state.memHistory[c.name] = [0];
+  pickFromList("Container Templates", items, idx => {
+    if (idx === templates.length) return editTemplate(null, showTemplates);
+    const t = templates[idx];
+    pickFromList(t.name, ["Run", "Edit", "Delete"], action => {
+      if (action === 0) runTemplate(t);
+      else if (action === 1) editTemplate(t, showTemplates);
+      else {
+        state.prefs.templates = templates.filter(x => x !== t);
+        savePrefs();
+        notify(`Deleted template ${t.name}`, "yellow");
+        showTemplates();
+      }
+    });
+  });
+}
+
+// Best effort: only catches ports published by other containers, not other host processes
+async function findPortConflicts(opts) {
+  const specs = [...opts.matchAll(/(?:^|\s)(?:-p|--publish)[\s=]+(\S+)/g)].map(m => m[1]);
+  const requested = specs.map(spec => {
+    const parts = spec.split(":");
+    return { spec, port: parts.length >= 2 ? parseInt(parts[parts.length - 2], 10) : NaN };
+  }).filter(r => Number.isInteger(r.port));
+  if (requested.length === 0) return [];
   
-  let out = `{bold}{cyan-fg}Stats: ${c.name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(60)}{/gray-fg}\n\n`;
+  const out = await dockerExec('ps --format "{{.Names}}|{{.Ports}}"');
+  const owners = new Map();
+  (out || "").split("\n").filter(Boolean).forEach(line => {
+    const [name, ports = ""] = line.split("|");
+    for (const m of ports.matchAll(/:(\d+)->/g)) owners.set(parseInt(m[1], 10), name);
+  });
   
-  if (!running) {
-    out += "{gray-fg}Container is not running\nPress [s] to start{/gray-fg}\n";
-  } else {
-    out += smoothChart(state.cpuHistory[c.name], 12, 55, "cyan", "CPU:   ") + "\n\n";
-    out += smoothChart(state.memHistory[c.name], 12, 55, "green", "Memory:") + "\n\n";
-    
-    const [rx, tx] = (st.netIO || "0B / 0B").split(" / ");
-    const [read, write] = (st.blockIO || "0B / 0B").split(" / ");
-    const parseBytes = s => parseFloat(s.match(/^([\d.]+)/)?.[1] || 0);
-    
-    out += `{bold}{yellow-fg}PIDs:{/yellow-fg}{/bold}     ${st.pids || "N/A"}\n`;
-    out += `{bold}{blue-fg}Net RX:{/blue-fg}{/bold}   ${humanBytes(parseBytes(rx))}\n`;
-    out += `{bold}{blue-fg}Net TX:{/blue-fg}{/bold}   ${humanBytes(parseBytes(tx))}\n`;
-    out += `{bold}{magenta-fg}Disk R:{/magenta-fg}{/bold}   ${humanBytes(parseBytes(read))}\n`;
-    out += `{bold}{magenta-fg}Disk W:{/magenta-fg}{/bold}   ${humanBytes(parseBytes(write))}\n`;
+  const taken = new Set([...owners.keys(), ...requested.map(r => r.port)]);
+  return requested.filter(r => owners.has(r.port)).map(r => {
+    let free = r.port + 1;
+    while (taken.has(free)) free++;
+    taken.add(free);
+    return { ...r, owner: owners.get(r.port), free };
+  });
+}
+
+// The new container may not show up in `ps` on the very next poll, so retry a few times
+async function followNewContainer(id, attempts = 5) {
+  for (let i = 0; i < attempts; i++) {
+    await updateContainers();
+    const idx = state.containers.findIndex(c => id.startsWith(c.id));
+    if (idx !== -1) {
+      ui.containersBox.focus();
+      ui.containersBox.select(idx);
+      state.selectedContainerIndex = idx;
+      state.currentTab = 0;
+      updateTabHeader();
+      showContainerLogs(state.containers[idx].name, "100");
+      screen.render();
+      return;
+    }
+    await new Promise(resolve => setTimeout(resolve, 1000));
   }
-  
-  ui.contentBox.setContent(out);
-  screen.render();
+  notify(`Container ${id.slice(0, 12)} not found; it may have exited and been removed`, "yellow");
 }
 
-async function updateEnvTab() {
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c) {
-    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
+async function showImagePlatforms(img) {
+  const ref = imageRef(img);
+  if (img.repo === "<none>") {
+    notify("Untagged image has no registry manifest", "yellow");
     return;
   }
   
-  if (state.env[c.name]) {
-    renderEnv(c.name, state.env[c.name]);
-    return;
-  }
+  notify(`Fetching manifest for ${ref}...`, "cyan");
+  const local = await dockerExec(`image inspect --format "{{.Os}}/{{.Architecture}}{{if .Variant}}/{{.Variant}}{{end}}" ${img.id}`);
+  let content = `{bold}Local copy:{/bold} ${local || "N/A"}\n\n{bold}{yellow-fg}Registry platforms:{/yellow-fg}{/bold}\n`;
   
-  const envVars = await getContainerEnv(c.name);
-  state.env[c.name] = envVars;
-  renderEnv(c.name, envVars);
+  try {
+    const { stdout } = await execPromise(`${dockerCmd} manifest inspect ${ref}`, { timeout: 30000 });
+    const manifest = JSON.parse(stdout);
+    const platforms = (manifest.manifests || [])
+      .map(m => m.platform)
+      .filter(p => p && p.os !== "unknown")
+      .map(p => [p.os, p.architecture, p.variant].filter(Boolean).join("/"));
+    content += platforms.length
+      ? platforms.map(p => `  ${p === local ? `{green-fg}${p} (local){/green-fg}` : p}`).join("\n")
+      : "  {gray-fg}Single-platform image (no manifest list){/gray-fg}";
+  } catch (error) {
+    const text = error.stderr || error.message;
+    content += /experimental/i.test(text)
+      ? "  {red-fg}'docker manifest' needs experimental CLI features on this docker version{/red-fg}"
+      : `  {red-fg}${text.trim().split("\n").pop()}{/red-fg}`;
+  }
+  showDialog(`Platforms: ${ref}`, content, "yellow");
 }
 
-function renderEnv(name, envVars) {
-  let content = `{bold}{cyan-fg}Environment Variables: ${name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(55)}{/gray-fg}\n\n`;
-  if (envVars.length === 0) {
-    content += "{yellow-fg}No environment variables found{/yellow-fg}\n";
-  } else {
-    envVars.forEach(env => {
-      const eqIdx = env.indexOf("=");
-      if (eqIdx > 0) {
-        const key = env.substring(0, eqIdx);
-        const val = env.substring(eqIdx + 1);
-        content += `{bold}${key}{/bold}={green-fg}${val}{/green-fg}\n`;
-      } else {
-        content += `${env}\n`;
-      }
+// Spawn a docker command and feed its combined output to onData; resolves with the exit code
+function streamDocker(args, onData, onSpawn) {
+  return new Promise(resolve => {
+    const [cmd, ...rest] = [...splitCommand(dockerCmd), ...args];
+    writeLogFile("CMD", [cmd, ...rest].join(" "));
+    const child = spawn(cmd, rest, { stdio: ["ignore", "pipe", "pipe"], env: dockerEnv() });
+    trackChild(child, [cmd, ...rest].join(" "));
+    if (onSpawn) onSpawn(child);
+    child.stdout.on("data", data => onData(data.toString()));
+    child.stderr.on("data", data => onData(data.toString()));
+    child.on("error", error => {
+      onData(`${error.message}\n`);
+      resolve(-1);
     });
-  }
-  ui.contentBox.setContent(content);
-  screen.render();
+    child.on("close", code => resolve(code));
+  });
 }
 
-async function updateConfigTab() {
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c) {
-    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
-    return;
-  }
-  
-  if (state.config[c.name]) {
-    renderConfig(c.name, state.config[c.name]);
-    return;
-  }
-  
-  const inspect = await getContainerInspect(c.name);
-  state.config[c.name] = inspect;
-  renderConfig(c.name, inspect);
+// Live-updating output dialog; returns an append function. onClose runs when the dialog is dismissed.
+function showOutputDialog(title, color = "cyan", onClose) {
+  const dialog = showDialog(title, "", color);
+  let text = "";
+  let closed = false;
+  dialog.on("destroy", () => {
+    closed = true;
+    if (onClose) onClose();
+  });
+  return chunk => {
+    if (closed) return;
+    text += chunk;
+    if (text.length > 200000) text = text.slice(-200000);
+    dialog.setContent(blessed.escape(text) + "\n{gray-fg}[Esc] close{/gray-fg}");
+    dialog.setScrollPerc(100);
+    screen.render();
+  };
+}
+
+// Mirrors configured in daemon.json ("registry-mirrors"); they only apply to Docker Hub images
+async function getRegistryMirrors() {
+  try {
+    const config = JSON.parse(await dockerExec('info --format "{{json .RegistryConfig}}"', 10000) || "{}");
+    return { mirrors: config.Mirrors || [], insecure: config.InsecureRegistryCIDRs || [] };
+  } catch (_) {
+    return { mirrors: [], insecure: [] };
+  }
+}
+
+function isDockerHubRepo(repo) {
+  const first = repo.split("/")[0];
+  return !repo.includes("/") || !/[.:]/.test(first) || first === "docker.io";
+}
+
+async function showRegistryMirrors() {
+  const { mirrors, insecure } = await getRegistryMirrors();
+  let content = "{bold}{yellow-fg}Registry mirrors:{/yellow-fg}{/bold}\n";
+  content += mirrors.length ? mirrors.map(m => `  ${m}`).join("\n") : "  {gray-fg}None configured{/gray-fg}";
+  content += "\n\n{bold}{yellow-fg}Insecure registries:{/yellow-fg}{/bold}\n";
+  content += insecure.length ? insecure.map(r => `  ${r}`).join("\n") : "  {gray-fg}None{/gray-fg}";
+  content += "\n\n{gray-fg}Docker Hub pulls go through the mirrors first and fall back to Docker Hub.\nSet them in the daemon's daemon.json (\"registry-mirrors\"); this view is read-only.{/gray-fg}";
+  return showDialog("Registry Configuration", content, "yellow");
+}
+
+function pullRepository(repo, tags) {
+  checkDiskSpace();
+  return queueOperation(`Pull ${repo}`, async () => {
+    const started = Date.now();
+    const append = showOutputDialog(`Pull ${repo}`, "yellow");
+    const { mirrors } = await getRegistryMirrors();
+    if (mirrors.length && isDockerHubRepo(repo)) append(`Using registry mirror(s): ${mirrors.join(", ")}\n`);
+    const results = [];
+    for (const tag of tags) {
+      append(`\n=== ${repo}:${tag} ===\n`);
+      const code = await streamDocker(["pull", `${repo}:${tag}`], append);
+      if (code === 0) rememberPull(`${repo}:${tag}`);
+      results.push(`${code === 0 ? "✓" : "✗"} ${repo}:${tag}`);
+    }
+    append(`\n=== Summary ===\n${results.join("\n")}\n`);
+    const failed = results.filter(r => r.startsWith("✗")).length;
+    notify(`Pulled ${tags.length - failed}/${tags.length} tag(s) of ${repo}`, failed ? "yellow" : "green");
+    notifyIfLong(started, !failed, `Pulled ${tags.length - failed}/${tags.length} tag(s) of ${repo}`);
+    await updateImages(true);
+  });
+}
+
+function showPullRepository(img) {
+  if (img.repo === "<none>") {
+    notify("Untagged image can't be pulled", "yellow");
+    return;
+  }
+  const tags = [...new Set(state.images.filter(i => i.repo === img.repo && i.tag !== "<none>").map(i => i.tag))];
+  if (tags.length === 0) tags.push("latest");
+  pickFromList(`Pull ${img.repo}`, [`All local tags (${tags.join(", ")})`, "latest only", "Another image..."], idx => {
+    if (idx === 2) return showPullImage();
+    pullRepository(img.repo, idx === 0 ? tags : ["latest"]);
+  });
+}
+
+// Recently pulled references, newest first; offered with local images when typing a reference
+const PULL_HISTORY_MAX = 20;
+
+function rememberPull(ref) {
+  state.prefs.pullHistory = [ref, ...(state.prefs.pullHistory || []).filter(r => r !== ref)].slice(0, PULL_HISTORY_MAX);
+  savePrefs();
+}
+
+function imageRefSuggestions() {
+  const local = state.images.filter(img => img.repo !== "<none>").map(imageRef);
+  return [...new Set([...(state.prefs.pullHistory || []), ...local])];
+}
+
+// "repo:tag" → [repo, tag]; a colon before the last slash is a registry port, not a tag
+function splitImageRef(ref) {
+  const at = ref.lastIndexOf(":");
+  return at > ref.lastIndexOf("/") ? [ref.slice(0, at), ref.slice(at + 1)] : [ref, "latest"];
+}
+
+function isValidImageRef(ref) {
+  return /^[a-z0-9][a-z0-9._\/:@-]*$/i.test(ref);
+}
+
+function showPullImage() {
+  promptImageRef("Image to pull (e.g. nginx:alpine)", "", ref => {
+    if (!ref) return;
+    if (!isValidImageRef(ref)) return notify(`Invalid image reference: ${ref}`, "red");
+    const [repo, tag] = splitImageRef(ref);
+    pullRepository(repo, [tag]);
+  });
+}
+
+// ==================== UPDATE CHECK ====================
+// One registry request per tag, spaced out so a long image list doesn't hammer the registry
+const UPDATE_CHECK_DELAY_MS = 1000;
+
+async function remoteDigest(ref) {
+  try {
+    const { stdout } = await execPromise(`${dockerCmd} buildx imagetools inspect --format "{{.Manifest.Digest}}" ${ref}`, { timeout: 30000 });
+    return stdout.trim() || null;
+  } catch (_) {
+    return null;
+  }
+}
+
+async function checkImageUpdates() {
+  if (state.checkingUpdates) {
+    notify("Update check already running", "yellow");
+    return;
+  }
+  const refs = [...new Set(state.images.filter(img => img.repo !== "<none>" && img.tag !== "<none>").map(imageRef))];
+  if (refs.length === 0) return;
+  
+  state.checkingUpdates = true;
+  notify(`Checking ${refs.length} image(s) for updates...`, "cyan");
+  let updates = 0, unknown = 0;
+  for (const [i, ref] of refs.entries()) {
+    if (i > 0) await new Promise(resolve => setTimeout(resolve, UPDATE_CHECK_DELAY_MS));
+    const [remote, local] = await Promise.all([remoteDigest(ref), dockerExec(`image inspect --format "{{json .RepoDigests}}" ${ref}`)]);
+    let digests = [];
+    try { digests = JSON.parse(local || "[]").map(d => d.split("@")[1]); } catch (_) {}
+    
+    // Locally built images have no RepoDigests, so there is nothing to compare against
+    const status = !remote || digests.length === 0 ? "unknown" : digests.includes(remote) ? "up to date" : "update available";
+    if (status === "update available") updates++;
+    if (status === "unknown") unknown++;
+    state.imageUpdates.set(ref, status);
+    await updateImages(true);
+    screen.render();
+  }
+  state.checkingUpdates = false;
+  notify(`${updates} update(s) available${unknown ? `, ${unknown} not checkable` : ""}`, updates ? "green" : "cyan");
+}
+
+// ==================== BUILD ====================
+const LARGE_CONTEXT_BYTES = 500 * 1024 * 1024;
+
+// Host path as seen by the docker CLI (C:\\src\\app -> /mnt/c/src/app when docker runs inside WSL)
+function toDockerPath(p) {
+  const m = usesWsl() && p.match(/^([A-Za-z]):[\\/](.*)$/);
+  return m ? `/mnt/${m[1].toLowerCase()}/${m[2].replace(/\\/g, "/")}` : p;
+}
+
+// .dockerignore glob to a matcher; supports *, **, ? and ! exceptions
+function dockerignoreMatcher(dir) {
+  let lines = [];
+  try { lines = fs.readFileSync(path.join(dir, ".dockerignore"), "utf8").split(/\r?\n/); } catch (_) {}
+  const rules = lines.map(l => l.trim()).filter(l => l && !l.startsWith("#")).map(line => {
+    const negate = line.startsWith("!");
+    const glob = line.replace(/^!/, "").replace(/^\/+|\/+$/g, "");
+    const re = glob.split(/(\*\*\/?|\*|\?)/).map(part =>
+      part.startsWith("**") ? ".*" : part === "*" ? "[^/]*" : part === "?" ? "[^/]" : part.replace(/[.+^${}()|[\]\\]/g, "\\$&")
+    ).join("");
+    return { negate, re: new RegExp(`^${re}(/.*)?$`) };
+  });
+  rules.unshift({ negate: false, re: /^\.git(\/.*)?$/ });
+  return rel => rules.reduce((ignored, rule) => rule.re.test(rel) ? !rule.negate : ignored, false);
+}
+
+async function contextSize(dir) {
+  const ignored = dockerignoreMatcher(dir);
+  let total = 0;
+  const walk = async rel => {
+    let entries = [];
+    try { entries = await fs.promises.readdir(path.join(dir, rel), { withFileTypes: true }); } catch (_) {}
+    for (const entry of entries) {
+      const child = rel ? `${rel}/${entry.name}` : entry.name;
+      if (entry.isDirectory()) {
+        await walk(child);
+      } else if (!ignored(child)) {
+        try { total += (await fs.promises.lstat(path.join(dir, child))).size; } catch (_) {}
+      }
+    }
+  };
+  await walk("");
+  return total;
+}
+
+function buildImage(dir, tag) {
+  checkDiskSpace();
+  return queueOperation(`Build ${tag || dir}`, async () => {
+    const started = Date.now();
+    const append = showOutputDialog(`Build ${tag || dir}`, "yellow");
+    const progress = state.prefs.buildProgress ?? "plain";
+    const args = ["build", ...(progress !== "off" ? [`--progress=${progress}`] : []), ...(tag ? ["-t", tag] : []), toDockerPath(dir)];
+    const code = await streamDocker(args, append);
+    append(`\n${code === 0 ? "✓ Build finished" : `✗ Build failed (exit ${code})`}\n`);
+    notify(code === 0 ? `Built ${tag || dir}` : "Build failed", code === 0 ? "green" : "red");
+    notifyIfLong(started, code === 0, code === 0 ? `Built ${tag || dir}` : `Build of ${tag || dir} failed (exit ${code})`);
+    await updateImages(true);
+  });
+}
+
+function showBuildImage(initialDir = process.cwd()) {
+  promptInput("Build context directory:", initialDir, dir => {
+    if (!dir) return;
+    const context = path.resolve(dir);
+    if (!fs.existsSync(path.join(context, "Dockerfile"))) {
+      notify(`No Dockerfile in ${context}`, "red");
+      return;
+    }
+    promptInput("Image tag (optional, e.g. myapp:dev):", "", async tag => {
+      notify("Measuring build context...", "cyan");
+      const size = await contextSize(context);
+      const build = () => buildImage(context, tag);
+      if (size < LARGE_CONTEXT_BYTES) return build();
+      confirmCommand(`Build context is ${humanBytes(size)} (after .dockerignore) — continue?`, ["build", ...(tag ? ["-t", tag] : []), context], build, { always: true });
+    });
+  });
+}
+
+// ==================== COMPOSE ====================
+async function getComposeServices() {
+  const out = await dockerExec('ps -a --format "{{.Labels}}|{{.State}}"');
+  const projects = {};
+  (out || "").split("\n").filter(Boolean).forEach(line => {
+    const sep = line.lastIndexOf("|");
+    const labels = line.substring(0, sep);
+    const project = labels.match(/com\.docker\.compose\.project=([^,]*)/)?.[1];
+    const service = labels.match(/com\.docker\.compose\.service=([^,]*)/)?.[1];
+    if (!project || !service) return;
+    projects[project] = projects[project] || {};
+    projects[project][service] = projects[project][service] || line.substring(sep + 1) === "running";
+  });
+  return projects;
+}
+
+// file: a compose file to use instead of a running project's name
+function streamComposeLogs(project, services, file) {
+  let child = null;
+  const append = showOutputDialog(`compose logs: ${project} (${services.join(", ") || "all"})`, "green", () => {
+    if (child) try { child.kill("SIGKILL"); } catch (_) {}
+  });
+  const target = file ? ["-f", toDockerPath(file)] : ["-p", project];
+  streamDocker(["compose", ...target, "logs", "-f", "--tail", "100", ...services], append, c => { child = c; })
+    .then(code => append(`\n--- log stream ended (exit ${code}) ---\n`));
+}
+
+async function showComposeLogs() {
+  const projects = await getComposeServices();
+  const names = Object.keys(projects).sort();
+  if (names.length === 0) {
+    notify("No compose projects found", "yellow");
+    return;
+  }
+  pickFromList("Compose project", names, idx => {
+    const project = names[idx];
+    const services = Object.keys(projects[project]).sort();
+    const items = services.map(svc => projects[project][svc] ? svc : `{gray-fg}${svc} (not running){/gray-fg}`);
+    pickMany(`Services in ${project} (none = all)`, items, chosen => {
+      streamComposeLogs(project, chosen.map(i => services[i]));
+    });
+  });
+}
+
+// ==================== OPEN FILE ====================
+// Terminals can't report drops, but dropping a file onto one types its path, so this prompt
+// accepts a dropped (or typed) path and picks the flow from the file type
+const COMPOSE_FILES = ["compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"];
+
+// Dropped paths arrive quoted ('/a b'), backslash-escaped (/a\ b) or as file:// URLs
+function droppedPath(text) {
+  let p = text.trim().replace(/^(['"])(.*)\1$/, "$2");
+  if (p.startsWith("file://")) p = decodeURIComponent(p.slice(7));
+  if (os.platform() !== "win32") p = p.replace(/\\(.)/g, "$1");
+  return path.resolve(p.replace(/^~(?=$|[\\/])/, os.homedir()));
+}
+
+function loadImage(file) {
+  return queueOperation(`Load ${path.basename(file)}`, async () => {
+    const started = Date.now();
+    const append = showOutputDialog(`Load ${path.basename(file)}`, "yellow");
+    const code = await streamDocker(["load", "-i", toDockerPath(file)], append);
+    append(`\n${code === 0 ? "✓ Loaded" : `✗ Load failed (exit ${code})`}\n`);
+    notify(code === 0 ? `Loaded ${path.basename(file)}` : "Load failed", code === 0 ? "green" : "red");
+    notifyIfLong(started, code === 0, `${code === 0 ? "Loaded" : "Failed to load"} ${path.basename(file)}`);
+    await updateImages(true);
+  });
+}
+
+function openDroppedFile(text) {
+  const file = droppedPath(text);
+  let stat;
+  try { stat = fs.statSync(file); } catch (_) {
+    return notify(`Not found: ${file}`, "red");
+  }
+  const base = path.basename(file).toLowerCase();
+  if (stat.isDirectory() || base === "dockerfile") return showBuildImage(stat.isDirectory() ? file : path.dirname(file));
+  if (/\.(tar|tar\.gz|tgz)$/.test(base)) return loadImage(file);
+  if (COMPOSE_FILES.includes(base)) return streamComposeLogs(path.basename(path.dirname(file)), [], file);
+  notify(`Don't know what to do with ${path.basename(file)} (expected a .tar, Dockerfile, directory or compose file)`, "yellow");
+}
+
+function showOpenFile() {
+  promptInput("Drop a file here or type a path (.tar → load, Dockerfile/dir → build, compose file → logs):", "", text => {
+    if (text) openDroppedFile(text);
+  });
+}
+
+// ==================== NETWORK ACTIONS ====================
+async function connectNetwork(net, container) {
+  return queueOperation(`Connect ${container} to ${net}`, async () => {
+    try {
+      await execPromise(`${dockerCmd} network connect ${net} ${container}`, { timeout: 10000 });
+      notify(`Connected ${container} to ${net}`, "green");
+      await updateAll();
+    } catch (error) {
+      notifyFailure(`Failed to connect: ${error.stderr?.trim() || error.message}`, () => connectNetwork(net, container));
+    }
+  });
+}
+
+async function disconnectNetwork(net, container) {
+  return queueOperation(`Disconnect ${container} from ${net}`, async () => {
+    try {
+      await execPromise(`${dockerCmd} network disconnect ${net} ${container}`, { timeout: 10000 });
+      notify(`Disconnected ${container} from ${net}`, "yellow");
+      await updateAll();
+    } catch (error) {
+      notifyFailure(`Failed to disconnect: ${error.stderr?.trim() || error.message}`, () => disconnectNetwork(net, container));
+    }
+  });
+}
+
+async function showNetworkInspect(name) {
+  const inspect = await getNetworkInspect(name);
+  if (!inspect) {
+    notify(`Failed to inspect network ${name}`, "red");
+    return;
+  }
+  
+  let content = `{bold}ID:{/bold} ${inspect.Id?.substring(0, 12) || "N/A"}\n`;
+  content += `{bold}Driver:{/bold} ${inspect.Driver || "N/A"}\n`;
+  content += `{bold}Scope:{/bold} ${inspect.Scope || "N/A"}\n`;
+  content += `{bold}Internal:{/bold} ${inspect.Internal ? "yes" : "no"}\n`;
+  content += `{bold}Created:{/bold} ${inspect.Created || "N/A"}\n\n`;
+  
+  content += `{bold}{yellow-fg}IPAM:{/yellow-fg}{/bold}\n`;
+  const ipam = inspect.IPAM?.Config || [];
+  if (ipam.length === 0) {
+    content += "  {gray-fg}No IPAM config{/gray-fg}\n";
+  } else {
+    ipam.forEach(cfg => {
+      content += `  Subnet: ${cfg.Subnet || "N/A"}\n`;
+      content += `  Gateway: ${cfg.Gateway || "N/A"}\n`;
+    });
+  }
+  content += "\n";
+  
+  content += `{bold}{green-fg}Connected Containers:{/green-fg}{/bold}\n`;
+  const containers = Object.values(inspect.Containers || {});
+  if (containers.length === 0) {
+    content += "  {gray-fg}None{/gray-fg}\n";
+  } else {
+    containers.forEach(c => {
+      content += `  {bold}${c.Name.padEnd(24)}{/bold} {cyan-fg}${(c.IPv4Address || "-").padEnd(18)}{/cyan-fg} ${c.MacAddress || ""}\n`;
+    });
+  }
+  showDialog(`Network: ${name}`, content, "blue");
+}
+
+async function createNetwork(name, driver, subnet) {
+  return queueOperation(`Create network ${name}`, async () => {
+    const subnetArg = subnet ? ` --subnet ${subnet}` : "";
+    try {
+      await execPromise(`${dockerCmd} network create --driver ${driver}${subnetArg} ${name}`, { timeout: 15000 });
+      notify(`Created network ${name}`, "green");
+      await updateNetworks();
+      screen.render();
+    } catch (error) {
+      notifyFailure(`Failed to create network: ${error.stderr?.trim() || error.message}`, () => createNetwork(name, driver, subnet));
+    }
+  });
+}
+
+function isValidCidr(cidr) {
+  const [addr, bits, ...rest] = cidr.split("/");
+  if (rest.length || bits === undefined || !/^\d+$/.test(bits)) return false;
+  if (addr.includes(":")) return /^[0-9a-fA-F:]+$/.test(addr) && +bits <= 128;
+  const octets = addr.split(".");
+  return octets.length === 4 && octets.every(o => /^\d+$/.test(o) && +o <= 255) && +bits <= 32;
+}
+
+const NETWORK_DRIVERS = ["bridge", "overlay", "macvlan", "ipvlan", "host", "none"];
+
+function showCreateNetwork() {
+  promptInput("New network name:", "", name => {
+    if (!name) return;
+    if (!/^[a-zA-Z0-9][a-zA-Z0-9_.-]*$/.test(name)) {
+      notify(`Invalid network name: ${name}`, "red");
+      return;
+    }
+    pickFromList("Driver", NETWORK_DRIVERS, idx => {
+      promptInput("Subnet (CIDR, optional):", "", subnet => {
+        if (subnet && !isValidCidr(subnet)) {
+          notify(`Invalid subnet: ${subnet}`, "red");
+          return;
+        }
+        createNetwork(name, NETWORK_DRIVERS[idx], subnet);
+      });
+    });
+  });
+}
+
+// ==================== VOLUME ACTIONS ====================
+function isValidVolumeName(name) {
+  return /^[a-zA-Z0-9][a-zA-Z0-9_.-]+$/.test(name);
+}
+
+async function createVolume(name) {
+  return queueOperation(`Create volume ${name}`, async () => {
+    try {
+      await execPromise(`${dockerCmd} volume create ${name}`, { timeout: 15000 });
+      notify(`Created volume ${name}`, "green");
+      await updateVolumes();
+      screen.render();
+    } catch (error) {
+      notifyFailure(`Failed to create volume: ${error.stderr?.trim() || error.message}`, () => createVolume(name));
+    }
+  });
+}
+
+// Host path a file manager can open for a docker-side path; null when it lives inside a VM we can't reach
+async function hostPathFor(dockerPath) {
+  if (usesWsl()) {
+    const distro = splitCommand(dockerCmd).join(" ").match(/(?:-d|--distribution)\s+(\S+)/)?.[1]
+      || (await execPromise('wsl sh -c "echo $WSL_DISTRO_NAME"', { timeout: 10000 }).catch(() => ({ stdout: "" }))).stdout.trim();
+    return distro ? `\\\\wsl$\\${distro}${dockerPath.replace(/\//g, "\\")}` : null;
+  }
+  if (isWindows) return `\\\\wsl$\\docker-desktop-data\\data\\docker${dockerPath.replace(/^\/var\/lib\/docker/, "").replace(/\//g, "\\")}`;
+  if (os.platform() === "darwin") return null;
+  return dockerPath;
+}
+
+async function openVolumeLocation(vol) {
+  if (vol.driver !== "local") {
+    notify(`${vol.name} uses the '${vol.driver}' driver; only local volumes have a browsable path`, "yellow");
+    return;
+  }
+  const mountpoint = await dockerExec(`volume inspect --format "{{.Mountpoint}}" ${vol.name}`);
+  if (!mountpoint) {
+    notify(`Failed to inspect volume ${vol.name}`, "red");
+    return;
+  }
+  const target = await hostPathFor(mountpoint);
+  if (!target) {
+    copyToClipboard(mountpoint);
+    notify(`${mountpoint} is inside the Docker VM; path copied instead`, "yellow");
+    return;
+  }
+  
+  const opener = isWindows ? "explorer.exe" : "xdg-open";
+  const child = spawn(opener, [target], { detached: true, stdio: "ignore" });
+  child.on("error", error => notify(`Failed to open ${target}: ${error.message}`, "red"));
+  child.unref();
+  notify(`Opening ${target}${isWindows ? "" : " (may need root to read)"}`, "cyan");
+}
+
+async function showVolumeDetails(vol) {
+  const users = await getVolumeUsers(vol.name);
+  let content = rowDetail(vol) + "\n\n{bold}{magenta-fg}Used By:{/magenta-fg}{/bold}\n";
+  if (users.length === 0) {
+    content += "  {gray-fg}No containers (safe to remove){/gray-fg}\n";
+  } else {
+    users.forEach(u => {
+      const color = u.state === "running" ? "green" : "gray";
+      content += `  {bold}${u.name}{/bold} {${color}-fg}${u.state}{/${color}-fg}\n`;
+    });
+  }
+  showDialog(`Volume: ${vol.name}`, content, "magenta");
+}
+
+// Warning line for the remove confirm when any of the volumes is still mounted
+async function volumeUsageWarning(names) {
+  const users = (await Promise.all(names.map(getVolumeUsers))).flat().map(u => u.name);
+  if (users.length === 0) return "";
+  return `\n{yellow-fg}In use by ${[...new Set(users)].join(", ")}; removal fails until they're removed{/yellow-fg}`;
+}
+
+async function networkContainers(net) {
+  const inspect = await getNetworkInspect(net);
+  return Object.values(inspect?.Containers || {}).map(c => c.Name);
+}
+
+// ==================== STATS STREAMING ====================
+function startStatsStream() {
+  state.streamsStopped = false;
+  if (state.statsProcess) try { state.statsProcess.kill(); } catch (_) {}
+  
+  const [cmd, ...args] = [...splitCommand(dockerCmd), "stats", "--no-stream=false", "--format", "table {{.Name}}\t{{.CPUPerc}}\t{{.MemPerc}}\t{{.MemUsage}}\t{{.NetIO}}\t{{.BlockIO}}\t{{.PIDs}}"];
+  state.statsProcess = spawn(cmd, args, { stdio: ["ignore", "pipe", "pipe"], env: dockerEnv() });
+  
+  let buffer = "";
+  state.statsProcess.stdout.on("data", chunk => {
+    buffer += chunk.toString();
+    const lines = buffer.split("\n");
+    buffer = lines.pop();
+    
+    lines.forEach(line => {
+      if (!line.trim() || line.startsWith("NAME")) return;
+      const parts = line.split(/\s{2,}|\t/);
+      if (parts.length < 7) return;
+      
+      const [name, cpuStr, memStr, memUsage, netIO, blockIO, pids] = parts;
+      if (!name) return;
+      
+      const cpu = parseFloat(cpuStr?.replace("%", "")) || 0;
+      const mem = parseFloat(memStr?.replace("%", "")) || 0;
+      
+      state.stats[name] = { cpu, mem, memUsage: memUsage || "N/A", netIO: netIO || "N/A", blockIO: blockIO || "N/A", pids: pids || "N/A" };
+      
+      if (!state.cpuHistory[name]) state.cpuHistory[name] = [];
+      if (!state.memHistory[name]) state.memHistory[name] = [];
+      
+      state.cpuHistory[name].push(cpu);
+      state.memHistory[name].push(mem);
+      
+      if (state.cpuHistory[name].length > MAX_HISTORY) state.cpuHistory[name].shift();
+      if (state.memHistory[name].length > MAX_HISTORY) state.memHistory[name].shift();
+    });
+    
+    if (!state.inFullscreenMode && state.currentTab === 1) updateStatsTab();
+  });
+  
+  state.statsProcess.on("error", error => logEvent("ERROR", `Failed to start stats stream: ${error.message}`));
+  
+  state.statsProcess.on("close", () => {
+    setTimeout(() => {
+      if (!state.inFullscreenMode && !state.streamsStopped && (!state.statsProcess || state.statsProcess.killed)) startStatsStream();
+    }, 2000);
+  });
+}
+
+// ==================== LOGS ====================
+function showContainerLogs(name, tail = "10") {
+  if (!name || state.inFullscreenMode) return;
+  stopLogStream();
+  
+  state.logsContent = "";
+  const timestamps = state.prefs.logTimestamps ? ["--timestamps"] : [];
+  const [cmd, ...args] = [...splitCommand(dockerCmd), "logs", "-f", ...timestamps, "--tail", tail, name];
+  state.logProcess = spawn(cmd, args, { stdio: ['ignore', 'pipe', 'pipe'], env: dockerEnv() });
+  
+  const onData = data => {
+    if (state.inFullscreenMode) return;
+    state.logsContent += data.toString();
+    if (state.logsContent.length > 100000) state.logsContent = state.logsContent.slice(-100000);
+    if (state.currentTab === 0) {
+      ui.contentBox.setContent(visibleLogs());
+      if (state.logsAutoScroll) ui.contentBox.setScrollPerc(100);
+      screen.render();
+    }
+  };
+  
+  state.logProcess.stdout.on("data", onData);
+  state.logProcess.stderr.on("data", onData);
+  
+  const proc = state.logProcess;
+  const onFailure = msg => {
+    if (state.logProcess !== proc) return;
+    logEvent("ERROR", `Logs for ${name}: ${msg}`);
+    state.logsContent += `\n{red-fg}${msg}{/red-fg}\n`;
+    if (state.currentTab === 0 && !state.inFullscreenMode) updateLogsTab();
+  };
+  proc.on("error", error => onFailure(`Failed to start log stream: ${error.message}`));
+  proc.on("close", code => {
+    if (code) onFailure(`Log stream exited with code ${code}`);
+  });
+}
+
+function visibleLogs() {
+  const content = state.prefs.logTimestamps === "local" ? localizeTimestamps(state.logsContent) : state.logsContent;
+  if (!state.logsFilter) return content;
+  return content.split("\n").filter(line => state.logsFilter.test(line)).join("\n");
+}
+
+// `logs --timestamps` prefixes RFC3339 UTC ("2024-05-01T12:00:00.123456789Z "); lines without one pass through
+const LOG_TIMESTAMP = /^(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2})(\.\d+)?Z /gm;
+
+function localizeTimestamps(text) {
+  return text.replace(LOG_TIMESTAMP, (match, base, frac) => {
+    const date = new Date(`${base}${(frac || "").slice(0, 4)}Z`);
+    if (isNaN(date)) return match;
+    const pad = n => String(n).padStart(2, "0");
+    return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())} ${pad(date.getHours())}:${pad(date.getMinutes())}:${pad(date.getSeconds())} `;
+  });
+}
+
+function setLogsFilter(pattern) {
+  if (!pattern) {
+    state.logsFilter = null;
+    ui.contentBox.setLabel("");
+    notify("Log filter cleared", "yellow");
+  } else {
+    try {
+      state.logsFilter = new RegExp(pattern, "i");
+    } catch (error) {
+      notify(`Invalid pattern: ${error.message}`, "red");
+      return;
+    }
+    ui.contentBox.setLabel(` Filter: /${pattern}/ `);
+  }
+  if (state.currentTab === 0) updateLogsTab();
+}
+
+const CRASH_KEYWORDS = /error|panic|fatal|exception|traceback|segfault|killed/i;
+
+// One <name>.log per container; stdout and stderr are interleaved as docker emits them
+// range: { since, until } as Dates; docker takes them as unix seconds
+function writeContainerLogs(name, file, range = {}) {
+  return new Promise(resolve => {
+    const out = fs.createWriteStream(file);
+    out.on("error", error => resolve(error.message));
+    out.on("open", () => {
+      const bounds = ["since", "until"].flatMap(k => range[k] ? [`--${k}`, String(Math.floor(range[k] / 1000))] : []);
+      const [cmd, ...args] = [...splitCommand(dockerCmd), "logs", "--timestamps", ...bounds, name];
+      const child = spawn(cmd, args, { stdio: ["ignore", "pipe", "pipe"], env: dockerEnv() });
+      trackChild(child, `logs ${name}`);
+      let stderr = "";
+      child.stdout.pipe(out, { end: false });
+      child.stderr.on("data", data => {
+        stderr = (stderr + data).slice(-500);
+        out.write(data);
+      });
+      child.on("error", error => { out.end(); resolve(error.message); });
+      child.on("close", code => out.end(() => resolve(code === 0 ? null : stderr.trim().split("\n").pop() || `exit ${code}`)));
+    });
+  });
+}
+
+function downloadLogs(names, dir, range = {}) {
+  return queueOperation(`Download logs (${names.length})`, async () => {
+    try {
+      fs.mkdirSync(dir, { recursive: true });
+    } catch (error) {
+      notify(`Cannot create ${dir}: ${error.message}`, "red");
+      return;
+    }
+    
+    const started = Date.now();
+    const append = showOutputDialog(`Download logs → ${dir}`, "cyan");
+    if (range.since) append(`Range: ${range.since.toLocaleString()} → ${(range.until || new Date()).toLocaleString()}\n\n`);
+    let failed = 0;
+    for (const [i, name] of names.entries()) {
+      const file = path.join(dir, `${name}.log`);
+      append(`[${i + 1}/${names.length}] ${name}... `);
+      const error = await writeContainerLogs(name, file, range);
+      if (error) failed++;
+      append(error ? `✗ ${error}\n` : `✓ ${humanBytes(fs.statSync(file).size)}\n`);
+    }
+    append(`\nWrote ${names.length - failed} of ${names.length} log file(s) to ${dir}\n`);
+    notify(`Saved logs for ${names.length - failed}/${names.length} container(s)`, failed ? "yellow" : "green");
+    notifyIfLong(started, !failed, `Saved logs for ${names.length - failed}/${names.length} container(s) to ${dir}`);
+  });
+}
+
+function showDownloadLogs() {
+  const names = state.markedContainers.size > 0
+    ? [...state.markedContainers]
+    : [state.containers[state.selectedContainerIndex]?.name].filter(Boolean);
+  if (names.length === 0) return;
+  
+  const stamp = new Date().toISOString().replace(/[:.]/g, "-").slice(0, 19);
+  const save = range => promptInput(`Save logs of ${names.length} container(s) to directory:`, path.join(process.cwd(), `logs-${stamp}`), dir => {
+    if (dir) downloadLogs(names, path.resolve(dir), range);
+  });
+  pickFromList("Download logs", ["Everything", "Time range..."], idx => idx === 0 ? save({}) : askLogRange(save));
+}
+
+// "30m", "2h", "1d" (ago), "now", or a local date/time like "2024-05-01 14:00"
+function parseLogTime(input) {
+  if (input === "now") return new Date();
+  const rel = /^(\d+)\s*([smhd])$/.exec(input);
+  if (rel) return new Date(Date.now() - rel[1] * { s: 1e3, m: 6e4, h: 36e5, d: 864e5 }[rel[2]]);
+  if (!/^\d{4}-\d{2}-\d{2}([ T]\d{2}:\d{2}(:\d{2})?)?$/.test(input)) return null;
+  const date = new Date(input.includes(":") ? input.replace(" ", "T") : `${input}T00:00`);
+  return isNaN(date) ? null : date;
+}
+
+function askLogRange(onRange) {
+  promptInput("From (e.g. 2h, 30m, 2024-05-01 14:00):", "1h", sinceStr => {
+    if (!sinceStr) return;
+    const since = parseLogTime(sinceStr);
+    if (!since) return notify(`Invalid time: ${sinceStr}`, "red");
+    promptInput("Until (now, 10m, 2024-05-01 15:00):", "now", untilStr => {
+      if (!untilStr) return;
+      const until = parseLogTime(untilStr);
+      if (!until) return notify(`Invalid time: ${untilStr}`, "red");
+      if (until <= since) return notify("'Until' must be after 'From'", "red");
+      onRange({ since, until });
+    });
+  });
+}
+
+async function showCrashLogs(c) {
+  let raw;
+  try {
+    const { stdout, stderr } = await execPromise(`${dockerCmd} logs --tail 500 ${c.name}`, { timeout: 30000, maxBuffer: 20 * 1024 * 1024 });
+    raw = stdout + stderr;
+  } catch (error) {
+    notify(`Failed to get logs: ${error.stderr?.trim() || error.message}`, "red");
+    return;
+  }
+  
+  const lines = raw.split("\n").map(line => {
+    const esc = blessed.escape(line);
+    return CRASH_KEYWORDS.test(line) ? `{red-fg}{bold}${esc}{/bold}{/red-fg}` : esc;
+  });
+  const hits = raw.split("\n").filter(line => CRASH_KEYWORDS.test(line)).length;
+  const header = `{bold}${c.name}{/bold} ${c.status}  {red-fg}${hits} suspicious line(s){/red-fg}  {gray-fg}[c] copy  [w] save{/gray-fg}\n\n`;
+  
+  const dialog = showDialog(`Last 500 lines: ${c.name}`, header + lines.join("\n"), "red");
+  dialog.setScrollPerc(100);
+  dialog.key(["c"], () => copyToClipboard(raw));
+  dialog.key(["w"], () => saveText(`${c.name}-logs-${Date.now()}.log`, raw));
+}
+
+function saveText(fileName, text) {
+  const file = path.join(process.cwd(), fileName);
+  try {
+    fs.writeFileSync(file, text);
+    notify(`Saved ${file}`, "green");
+  } catch (error) {
+    notify(`Failed to save: ${error.message}`, "red");
+  }
+}
+
+function stopLogStream() {
+  if (state.logProcess) {
+    try {
+      if (state.logProcess.stdout) state.logProcess.stdout.destroy();
+      if (state.logProcess.stderr) state.logProcess.stderr.destroy();
+      state.logProcess.kill('SIGKILL');
+    } catch (_) {}
+    state.logProcess = null;
+  }
+}
+
+// ==================== CHARTS ====================
+function smoothChart(data, height = 12, width = 60, color = "cyan", label = "") {
+  if (!data || data.length < 2) {
+    return Array(height).fill(" ".repeat(width)).join("\n") + `\n{${color}-fg}        ${label} 0.00 % (waiting…){/${color}-fg}`;
+  }
+  
+  const slice = data.slice(-width);
+  const max = Math.max(...slice);
+  const min = Math.min(...slice);
+  const range = max - min || 1;
+  
+  const dots = [[0x01, 0x08], [0x02, 0x10], [0x04, 0x20], [0x40, 0x80]];
+  const pxW = width * 2, pxH = height * 4;
+  const canvas = Array.from({ length: pxH }, () => Array(pxW).fill(0));
+  const y = v => Math.round(pxH - 1 - ((v - min) / range) * (pxH - 1));
+  
+  for (let i = 0; i < slice.length - 1; i++) {
+    const x0 = Math.round((i / (slice.length - 1)) * (pxW - 1));
+    const x1 = Math.round(((i + 1) / (slice.length - 1)) * (pxW - 1));
+    const y0 = y(slice[i]), y1 = y(slice[i + 1]);
+    const steps = Math.max(Math.abs(x1 - x0), Math.abs(y1 - y0)) * 2;
+    
+    for (let s = 0; s <= steps; s++) {
+      const t = s / steps;
+      const sx = Math.round(x0 + (x1 - x0) * t);
+      const sy = Math.round(y0 + (y1 - y0) * t);
+      for (let dy = 0; dy <= 1; dy++) {
+        for (let dx = 0; dx <= 1; dx++) {
+          const cx = sx + dx, cy = sy + dy;
+          if (cx >= 0 && cx < pxW && cy >= 0 && cy < pxH) canvas[cy][cx] = 1;
+        }
+      }
+    }
+  }
+  
+  const rows = [];
+  for (let row = 0; row < pxH; row += 4) {
+    let line = "";
+    for (let col = 0; col < pxW; col += 2) {
+      let code = 0x2800;
+      for (let dy = 0; dy < 4; dy++) {
+        for (let dx = 0; dx < 2; dx++) {
+          if (canvas[row + dy]?.[col + dx]) code |= dots[dy][dx];
+        }
+      }
+      line += String.fromCharCode(code);
+    }
+    const val = max - (row / 4 / (height - 1)) * range;
+    rows.push(`${val.toFixed(2).padStart(6)} │{${color}-fg}${line}{/${color}-fg}`);
+  }
+  
+  rows.push("       └" + "─".repeat(width));
+  const cur = slice[slice.length - 1];
+  rows.push(`\n{${color}-fg}        ${label} ${cur.toFixed(2)} %  (${slice.length * 2}s){/${color}-fg}`);
+  return rows.join("\n");
+}
+
+function humanBytes(n) {
+  const units = ["B", "kB", "MB", "GB", "TB"];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+  return `${n.toFixed(1)}${units[i]}`;
+}
+
+// ==================== UI UPDATES ====================
+function updateTabHeader() {
+  let header = "";
+  TAB_NAMES.forEach((name, i) => {
+    header += i === state.currentTab ? `{cyan-fg}{bold} ${name} {/bold}{/cyan-fg}` : `{gray-fg} ${name} {/gray-fg}`;
+    if (i < TAB_NAMES.length - 1) header += "{white-fg}-{/white-fg}";
+  });
+  ui.tabHeader.setContent(header);
+}
+
+function updateHelpBar() {
+  const pending = state.pendingOps > 0 ? `{yellow-fg}{bold}⏳ ${state.pendingOps} queued{/bold}{/yellow-fg} ` : "";
+  ui.helpBar.setContent(pending + "{bold}q{/}:Quit {bold}←→{/}:Tabs {bold}↑↓{/}:Nav {bold}s{/}:Start/Stop {bold}r{/}:Restart {bold}t{/}:Exec {bold}d{/}:Delete {bold}m{/}:Mark {bold}C-a{/}:SelectAll {bold}l{/}:Logs {bold}a{/}:AutoScroll {bold}/{/}:Filter {bold}F5{/}:Refresh");
+}
+
+function markCell(marked) {
+  if (state.prefs.compact) return marked ? "{white-bg}{black-fg}✓{/black-fg}{/white-bg}" : " ";
+  return marked ? "{white-bg}{black-fg}[✓]{/black-fg}{/white-bg} " : "    ";
+}
+
+// Compact view drops the Device box and narrows the mark gutter so more rows fit
+function applyLayout() {
+  const compact = !!state.prefs.compact;
+  compact ? ui.projectBox.hide() : ui.projectBox.show();
+  ui.containersBox.top = compact ? 0 : 3;
+  ui.containersBox.height = compact ? "30%" : "30%-3";
+  const drawer = !!state.prefs.statusDrawer;
+  drawer ? ui.statusBox.show() : ui.statusBox.hide();
+  ui.contentBox.height = drawer ? `100%-${4 + STATUS_DRAWER_HEIGHT}` : "100%-4";
+  if (drawer) renderStatusDrawer();
+  screen.render();
+}
+
+// Last few event log lines under the content panel; collapsed by default
+function renderStatusDrawer() {
+  if (!state.prefs.statusDrawer) return;
+  const colors = { ERROR: "red", WARN: "yellow" };
+  const lines = state.eventLog.slice(-(STATUS_DRAWER_HEIGHT - 2)).map(line => {
+    const color = colors[line.match(/\[(\w+)\]/)?.[1]];
+    return color ? `{${color}-fg}${blessed.escape(line)}{/${color}-fg}` : blessed.escape(line);
+  });
+  ui.statusBox.setContent(lines.join("\n"));
+}
+
+function toggleStatusDrawer() {
+  state.prefs.statusDrawer = !state.prefs.statusDrawer;
+  savePrefs();
+  applyLayout();
+}
+
+// keepIndex: where the previously selected row ended up after a re-sort (-1 = keep the position)
+function updateListIfChanged(list, newData, formatFn, indexRef, keepIndex = -1) {
+  if (!newData || newData.length === 0) {
+    const def = ["{yellow-fg}No items{/yellow-fg}"];
+    if (list.items.length !== 1 || list.items[0].content !== def[0]) {
+      list.setItems(def);
+      list.select(0);
+      screen.render();
+    }
+    indexRef[0] = 0;
+    return;
+  }
+  
+  const newItems = newData.map(formatFn);
+  const hasChanged = list.items.length !== newItems.length || list.items.some((item, i) => item.content !== newItems[i]);
+  const setHovers = () => list.items.forEach((item, i) => newData[i] && item.setHover(rowSummary(newData[i])));
+  
+  if (hasChanged) {
+    const wasFocused = screen.focused === list;
+    const cur = list.selected;
+    list.setItems(newItems);
+    const idx = keepIndex >= 0 ? keepIndex : Math.min(cur, newItems.length - 1);
+    // select() also scrolls the row into view, so a moved selection stays visible
+    list.select(Math.max(0, idx));
+    if (wasFocused) list.focus();
+    setHovers();
+    screen.render();
+    indexRef[0] = Math.max(0, idx);
+  } else {
+    setHovers();
+    indexRef[0] = list.selected;
+  }
+}
+
+// Full, untruncated row text for hover tooltips and the details dialog
+function rowSummary(row) {
+  return Object.values(row).filter(Boolean).join(" | ");
+}
+
+function rowDetail(row) {
+  return Object.entries(row).map(([key, val]) => `{bold}${key}:{/bold} ${val || "-"}`).join("\n");
+}
+
+async function updateContainers() {
+  try {
+    const selected = state.containers[state.selectedContainerIndex];
+    const selectedName = selected?.name;
+    const all = await getContainers();
+    forgetVanished(state.markedContainers, all.map(c => c.name), "container");
+    all.forEach(c => {
+      Object.assign(c, state.containerDetails[c.id]?.fields);
+      const code = c.state === "running" ? null : parseStatus(c.status).exitCode;
+      if (code !== null) c.exit = exitMeaning(code);
+    });
+    state.containers = sortRows(ui.containersBox, applySearch(all));
+    updatePanelLabel(ui.containersBox, state.containers.length, all.length);
+    const fmt = c => {
+      const st = state.stats[c.name] || { cpu: 0, mem: 0 };
+      const running = c.state === "running";
+      const paused = c.status.includes("Paused");
+      const code = parseStatus(c.status).exitCode;
+      const exited = code ? `{red-fg}exited ${code}{/red-fg}` : "{gray-fg}exited{/gray-fg}";
+      let status = running ? (paused ? "{yellow-fg}paused{/yellow-fg}" : "{green-fg}running{/green-fg}") : exited;
+      if (c.status.includes("healthy")) status = "{green-fg}running (healthy){/green-fg}";
+      if (state.loopAlerted.has(c.name)) status = "{red-fg}crash loop{/red-fg}";
+      const mark = markCell(state.markedContainers.has(c.name));
+      const name = pinStar(ui.containersBox, c) + c.name.substring(0, 17).padEnd(17);
+      const cpu = running ? `${st.cpu.toFixed(2)}%`.padStart(7) : "      -";
+      const ports = (c.ports?.substring(0, 12) || "").padEnd(12);
+      const ip = c.ip || "—";
+      // "12.3MB (virtual 187MB)": only the writable layer is interesting here
+      const size = c.size !== undefined ? `{yellow-fg}${c.size.split(" ")[0].padEnd(8)}{/yellow-fg}` : "";
+      const show = columnShown("containers");
+      return mark + [
+        show("status") && status.padEnd(25),
+        `${show("restart") ? restartBadge(c.restart, c.autoRemove) : ""}{bold}${name}{/bold}`,
+        show("cpu") && cpu,
+        show("size") && size,
+        show("ports") && `{cyan-fg}${ports}{/cyan-fg}`,
+        show("ip") && `{gray-fg}${ip}{/gray-fg}`,
+      ].filter(Boolean).join(" ");
+    };
+    // Follow the selected container by ID when a refresh reorders the list
+    const keepIndex = selected ? state.containers.findIndex(c => c.id === selected.id) : -1;
+    updateListIfChanged(ui.containersBox, state.containers, fmt, [state.selectedContainerIndex], keepIndex);
+    state.selectedContainerIndex = ui.containersBox.selected;
+    updateHelpBar();
+    refreshContainerDetails(all);
+    
+    // Removed (or hidden) behind our back: don't leave streams and tabs pointing at it
+    if (selectedName && !all.some(c => c.name === selectedName)) {
+      if (!wasExpectedGone(selectedName)) {
+        const gone = state.prefs.hideStopped ? "is no longer running" : "no longer exists";
+        logEvent("WARN", `Selected container ${selectedName} ${gone}`);
+        notify(`${selectedName} ${gone}`, "yellow");
+      }
+      if (state.logProcess?.spawnargs?.includes(selectedName)) stopLogStream();
+      delete state.env[selectedName];
+      delete state.config[selectedName];
+      delete state.top[selectedName];
+      await updateCurrentTab();
+    }
+  } catch (err) {
+    ui.containersBox.setItems([`{red-fg}Error: ${err.message}{/red-fg}`]);
+  }
+}
+
+// Common exit codes; 128+N means the process died from signal N
+const EXIT_CODES = new Map([
+  [0, "clean exit"],
+  [1, "application error"],
+  [125, "docker run failed"],
+  [126, "command not executable"],
+  [127, "command not found"],
+  [130, "SIGINT"],
+  [137, "SIGKILL, often out of memory"],
+  [139, "SIGSEGV, segfault"],
+  [143, "SIGTERM"],
+]);
+
+function exitMeaning(code) {
+  const meaning = EXIT_CODES.get(code) || (code > 128 && code < 160 ? `signal ${code - 128}` : "");
+  return meaning ? `${code} (${meaning})` : String(code);
+}
+
+// ==================== SAVED COMMANDS ====================
+// Per-container shortcuts, stored as prefs.savedCommands[containerName] = [{ name, cmd }]
+function runSavedCommand(c, entry) {
+  const dialog = showDialog(`${c.name}: ${entry.name}`, "", "green");
+  let output = `$ ${entry.cmd}\n`;
+  let footer = "{gray-fg}Running... [Esc] close{/gray-fg}";
+  let closed = false;
+  dialog.on("destroy", () => { closed = true; });
+  const render = () => {
+    if (closed) return;
+    dialog.setContent(`${blessed.escape(output)}\n${footer}`);
+    dialog.setScrollPerc(100);
+    screen.render();
+  };
+  dialog.key(["c"], () => copyToClipboard(output));
+  render();
+  streamDocker(["exec", c.name, "sh", "-c", entry.cmd], chunk => {
+    output += chunk;
+    render();
+  }).then(code => {
+    footer = `{${code === 0 ? "green" : "red"}-fg}exit ${code}{/${code === 0 ? "green" : "red"}-fg}  {gray-fg}[c] copy output  [Esc] close{/gray-fg}`;
+    render();
+  });
+}
+
+function showSavedCommands(c) {
+  if (!c) return;
+  const all = state.prefs.savedCommands || {};
+  const saved = all[c.name] || [];
+  const persist = list => {
+    state.prefs.savedCommands = { ...all, [c.name]: list };
+    if (list.length === 0) delete state.prefs.savedCommands[c.name];
+    savePrefs();
+  };
+  
+  const items = saved.map(e => `${e.name.padEnd(20)} {gray-fg}${e.cmd}{/gray-fg}`);
+  items.push("{cyan-fg}+ Add command...{/cyan-fg}");
+  if (saved.length) items.push("{red-fg}- Delete a command...{/red-fg}");
+  
+  pickFromList(`Commands: ${c.name}`, items, idx => {
+    if (idx < saved.length) {
+      if (c.state !== "running") return notify("Container must be running", "red");
+      return runSavedCommand(c, saved[idx]);
+    }
+    if (idx === saved.length) {
+      return promptInput("Command name (e.g. migrate db):", "", name => {
+        if (!name) return;
+        promptInput(`Shell command for '${name}' (runs with sh -c):`, "", cmd => {
+          if (!cmd) return;
+          persist([...saved.filter(e => e.name !== name), { name, cmd }]);
+          notify(`Saved '${name}' for ${c.name}`, "green");
+        });
+      });
+    }
+    pickFromList("Delete command", saved.map(e => e.name), del => {
+      persist(saved.filter((_, i) => i !== del));
+      notify(`Deleted '${saved[del].name}'`, "yellow");
+    });
+  });
+}
+
+// ==================== COMPARE ====================
+// Flattens the parts of inspect that usually explain "works there, not here" into field -> text
+function comparableFields(info) {
+  const host = info.HostConfig || {};
+  const cfg = info.Config || {};
+  const fields = {
+    Image: cfg.Image,
+    Command: [...(cfg.Entrypoint || []), ...(cfg.Cmd || [])].join(" "),
+    User: cfg.User || "(default)",
+    WorkingDir: cfg.WorkingDir || "(default)",
+    Restart: host.RestartPolicy?.Name || "no",
+    Limits: formatLimits(host),
+    Networks: Object.keys(info.NetworkSettings?.Networks || {}).sort().join(", "),
+    Ports: Object.entries(host.PortBindings || {}).map(([port, binds]) => `${(binds || []).map(b => b.HostPort).join("/")}->${port}`).sort().join(", "),
+  };
+  (info.Mounts || []).forEach(m => { fields[`Mount ${m.Destination}`] = `${m.Type}:${m.Name || m.Source}${m.RW ? "" : " (ro)"}`; });
+  (cfg.Env || []).forEach(kv => {
+    const i = kv.indexOf("=");
+    fields[`Env ${kv.slice(0, i)}`] = kv.slice(i + 1);
+  });
+  return fields;
+}
+
+async function showCompare(names) {
+  const infos = (await Promise.all(names.map(name => {
+    const c = state.containers.find(x => x.name === name);
+    return c ? getInspect(c) : null;
+  }))).filter(Boolean);
+  if (infos.length !== 2) {
+    notify("Failed to inspect both containers", "red");
+    return;
+  }
+  
+  const [a, b] = infos.map(comparableFields);
+  const keys = [...new Set([...Object.keys(a), ...Object.keys(b)])];
+  const col = 36;
+  const cell = v => blessed.escape((v ?? "—").length > col ? `${(v ?? "").slice(0, col - 1)}…` : (v ?? "—")).padEnd(col);
+  let differing = 0;
+  const rows = keys.map(key => {
+    const same = a[key] === b[key];
+    if (!same) differing++;
+    const line = `${key.slice(0, 22).padEnd(23)} ${cell(a[key])} ${cell(b[key])}`;
+    return same ? `{gray-fg}${line}{/gray-fg}` : `{yellow-fg}${line}{/yellow-fg}`;
+  });
+  const header = `{bold}${"".padEnd(23)} ${names[0].slice(0, col).padEnd(col)} ${names[1].slice(0, col).padEnd(col)}{/bold}`;
+  showDialog(`Compare (${differing} difference${differing === 1 ? "" : "s"} in yellow)`, [header, ...rows].join("\n"), "yellow");
+}
+
+// ==================== HEALTH ====================
+async function showHealth(c) {
+  // Always fresh: the health log changes on every check interval
+  const health = (await getInspect(c, 0))?.State?.Health;
+  if (!health) {
+    notify(`${c.name} has no healthcheck configured`, "yellow");
+    return;
+  }
+  
+  const colors = { healthy: "green", unhealthy: "red", starting: "yellow" };
+  const color = colors[health.Status] || "white";
+  let content = `{bold}Status:{/bold} {${color}-fg}${health.Status}{/${color}-fg}   {bold}Failing streak:{/bold} ${health.FailingStreak ?? 0}\n\n`;
+  const log = health.Log || [];
+  if (log.length === 0) content += "{gray-fg}No checks have run yet{/gray-fg}\n";
+  [...log].reverse().forEach(entry => {
+    const ok = entry.ExitCode === 0;
+    const started = new Date(entry.Start).toLocaleString();
+    const ms = new Date(entry.End) - new Date(entry.Start);
+    content += `{${ok ? "green" : "red"}-fg}{bold}${ok ? "✓" : "✗"} exit ${entry.ExitCode}{/bold}{/${ok ? "green" : "red"}-fg}  ${started}  {gray-fg}${Number.isFinite(ms) ? `${ms}ms` : ""}{/gray-fg}\n`;
+    const output = (entry.Output || "").trim();
+    if (output) content += `  ${blessed.escape(output).split("\n").join("\n  ")}\n`;
+    content += "\n";
+  });
+  showDialog(`Health: ${c.name} (newest first)`, content, color === "white" ? "cyan" : color);
+}
+
+// Drop marks for resources that disappeared so bulk actions never target ghosts
+function forgetVanished(marked, existing, kind) {
+  if (marked.size === 0) return;
+  const present = new Set(existing);
+  [...marked].filter(key => !present.has(key)).forEach(key => {
+    marked.delete(key);
+    logEvent("WARN", `Marked ${kind} ${key} no longer exists; unmarked`);
+  });
+}
+
+// ==================== CONTAINER DETAILS ====================
+// Fields docker ps can't provide, filled in by a batched background inspect.
+// A container is re-inspected when it is new, its state changed, or its entry is
+// older than INSPECT_TTL_MS; the raw inspect is kept for detail views (getInspect).
+const INSPECT_TTL_MS = 60000;
+const INSPECT_BATCH = 25;
+
+function containerDetailFields(info) {
+  return {
+    restart: info.HostConfig?.RestartPolicy?.Name || "no",
+    autoRemove: info.HostConfig?.AutoRemove ? "removed on exit (--rm)" : "",
+    limits: formatLimits(info.HostConfig),
+    ip: primaryIp(info),
+  };
+}
+
+function primaryIp(info) {
+  const networks = Object.values(info.NetworkSettings?.Networks || {});
+  return info.NetworkSettings?.IPAddress || networks.find(n => n.IPAddress)?.IPAddress || "—";
+}
+
+function formatLimits(hostConfig = {}) {
+  const cpus = hostConfig.NanoCpus ? `${+(hostConfig.NanoCpus / 1e9).toFixed(2)} CPU` : "";
+  const mem = hostConfig.Memory ? `${(hostConfig.Memory / 1024 / 1024).toFixed(0)}MB` : "";
+  return [cpus, mem].filter(Boolean).join(" / ") || "unlimited";
+}
+
+async function refreshContainerDetails(containers) {
+  if (state.detailsLoading) return;
+  const now = Date.now();
+  const stale = containers.filter(c => {
+    const entry = state.containerDetails[c.id];
+    return entry?.state !== c.state || now - entry.at > INSPECT_TTL_MS;
+  });
+  if (stale.length === 0) return;
+  
+  state.detailsLoading = true;
+  let parsed = [];
+  for (let i = 0; i < stale.length; i += INSPECT_BATCH) {
+    const batch = stale.slice(i, i + INSPECT_BATCH).map(c => c.id);
+    try {
+      parsed.push(...JSON.parse(await dockerExec(`inspect ${batch.join(" ")}`, 15000, { poll: true }) || "[]"));
+    } catch (_) {}
+  }
+  
+  const details = {};
+  containers.forEach(c => { if (state.containerDetails[c.id]) details[c.id] = state.containerDetails[c.id]; });
+  stale.forEach(c => {
+    const info = parsed.find(i => i.Id?.startsWith(c.id));
+    details[c.id] = { state: c.state, at: now, info, fields: info ? containerDetailFields(info) : {} };
+  });
+  state.containerDetails = details;
+  state.detailsLoading = false;
+  
+  if (parsed.length > 0) {
+    await updateContainers();
+    screen.render();
+  }
+}
+
+// Cached inspect for a listed container, fetched on a miss or when the entry is stale
+async function getInspect(c, maxAgeMs = INSPECT_TTL_MS) {
+  const entry = state.containerDetails[c.id];
+  if (entry?.info && entry.state === c.state && Date.now() - entry.at <= maxAgeMs) return entry.info;
+  try {
+    const [info] = JSON.parse(await dockerExec(`inspect ${c.id}`, 10000) || "[]");
+    if (info) state.containerDetails[c.id] = { state: c.state, at: Date.now(), info, fields: containerDetailFields(info) };
+    return info || null;
+  } catch (_) {
+    return null;
+  }
+}
+
+// --rm and restart policies are mutually exclusive, so they share the badge column
+function restartBadge(policy, autoRemove) {
+  if (autoRemove) return "{magenta-fg}⌛{/magenta-fg} ";
+  const badges = { always: "{cyan-fg}↻{/cyan-fg} ", "unless-stopped": "{blue-fg}↻{/blue-fg} ", "on-failure": "{yellow-fg}↻{/yellow-fg} " };
+  return badges[policy] || "  ";
+}
+
+async function updateImages(force = false) {
+  try {
+    const all = await getImages();
+    forgetVanished(state.markedImages, all.map(img => img.id), "image");
+    all.forEach(img => {
+      const update = state.imageUpdates.get(imageRef(img));
+      if (update) img.update = update;
+    });
+    const imgs = sortRows(ui.imagesBox, applySearch(all));
+    updatePanelLabel(ui.imagesBox, imgs.length, all.length);
+    if (!force && JSON.stringify(imgs) === JSON.stringify(state.images)) return;
+    state.images = imgs;
+    const fmt = img => {
+      const mark = markCell(state.markedImages.has(img.id));
+      const show = columnShown("images");
+      const update = show("update") && img.update === "update available" ? "{green-fg}⬆{/green-fg}" : "";
+      return mark + [
+        pinStar(ui.imagesBox, img) + img.repo.substring(0, 19).padEnd(19),
+        show("tag") && `{yellow-fg}${img.tag.substring(0, 10).padEnd(10)}{/yellow-fg}`,
+        show("size") && img.size.padEnd(10),
+      ].filter(Boolean).join(" ") + update;
+    };
+    updateListIfChanged(ui.imagesBox, state.images, fmt, [state.selectedImageIndex]);
+    state.selectedImageIndex = ui.imagesBox.selected;
+  } catch { ui.imagesBox.setItems(["{red-fg}Error{/red-fg}"]); }
+}
+
+async function updateVolumes(force = false) {
+  try {
+    const all = await getVolumes();
+    forgetVanished(state.markedVolumes, all.map(v => v.name), "volume");
+    if (state.prefs.volumeSizes) {
+      all.forEach(v => { v.size = state.volumeSizes.sizes[v.name] || "—"; });
+      refreshVolumeSizes();
+    }
+    const vols = sortRows(ui.volumesBox, applySearch(all));
+    updatePanelLabel(ui.volumesBox, vols.length, all.length);
+    if (!force && JSON.stringify(vols) === JSON.stringify(state.volumes)) return;
+    state.volumes = vols;
+    const fmt = v => {
+      const mark = markCell(state.markedVolumes.has(v.name));
+      const size = v.size ? `{yellow-fg}${v.size.padEnd(9)}{/yellow-fg}` : "";
+      const show = columnShown("volumes");
+      return mark + [
+        show("driver") && `{magenta-fg}${v.driver.padEnd(8)}{/magenta-fg}`,
+        show("size") && size,
+        v.name,
+      ].filter(Boolean).join(" ");
+    };
+    updateListIfChanged(ui.volumesBox, state.volumes, fmt, [state.selectedVolumeIndex]);
+    state.selectedVolumeIndex = ui.volumesBox.selected;
+  } catch { ui.volumesBox.setItems(["{red-fg}Error{/red-fg}"]); }
+}
+
+// `system df -v` walks every volume, so it is opt-in and re-run at most once a minute
+const VOLUME_SIZE_TTL_MS = 60000;
+
+async function refreshVolumeSizes() {
+  const cache = state.volumeSizes;
+  if (cache.loading || Date.now() - cache.at < VOLUME_SIZE_TTL_MS) return;
+  cache.loading = true;
+  const out = await dockerExec("system df -v", 120000);
+  cache.loading = false;
+  cache.at = Date.now();
+  if (out === null) return;
+  cache.sizes = parseVolumeSizes(out);
+  await updateVolumes(true);
+  screen.render();
+}
+
+// The "Local Volumes space usage:" table: VOLUME NAME, LINKS, SIZE
+function parseVolumeSizes(out) {
+  const sizes = {};
+  const lines = out.split("\n");
+  const start = lines.findIndex(line => line.startsWith("Local Volumes"));
+  const header = lines.findIndex((line, i) => start !== -1 && i > start && line.startsWith("VOLUME NAME"));
+  if (header === -1) return sizes;
+  for (const line of lines.slice(header + 1)) {
+    if (!line.trim()) break;
+    const parts = line.trim().split(/\s+/);
+    if (parts.length >= 3) sizes[parts[0]] = parts[parts.length - 1];
+  }
+  return sizes;
+}
+
+async function updateNetworks(force = false) {
+  try {
+    const all = await getNetworks();
+    const nets = applySearch(all);
+    updatePanelLabel(ui.networksBox, nets.length, all.length);
+    if (!force && JSON.stringify(nets) === JSON.stringify(state.networks)) return;
+    state.networks = nets;
+    const sys = ['bridge', 'host', 'none'];
+    const driver = columnShown("networks")("driver");
+    const fmt = n => {
+      const drv = driver ? `${n.driver.padEnd(8)} ` : "";
+      return sys.includes(n.name) ? `{gray-fg}${drv}${n.name} (system){/gray-fg}` : `{blue-fg}${drv}{/blue-fg}${n.name}`;
+    };
+    updateListIfChanged(ui.networksBox, state.networks, fmt, [state.selectedNetworkIndex]);
+    state.selectedNetworkIndex = ui.networksBox.selected;
+  } catch { ui.networksBox.setItems(["{red-fg}Error{/red-fg}"]); }
+}
+
+// ==================== COLUMNS ====================
+// Optional columns per panel (the name is always shown); hidden keys persist in prefs.hiddenColumns[kind]
+const COLUMNS = new Map([
+  [ui.containersBox, { kind: "containers", columns: [
+    ["status", "Status"], ["restart", "Restart policy badge"], ["cpu", "CPU %"],
+    ["size", "Size (with the Container sizes setting)"], ["ports", "Ports"], ["ip", "IP address"],
+  ] }],
+  [ui.imagesBox, { kind: "images", columns: [["tag", "Tag"], ["size", "Size"], ["update", "Update available (⬆)"]] }],
+  [ui.volumesBox, { kind: "volumes", columns: [["driver", "Driver"], ["size", "Size (with the Volume sizes setting)"]] }],
+  [ui.networksBox, { kind: "networks", columns: [["driver", "Driver"]] }],
+]);
+
+function columnShown(kind) {
+  const hidden = state.prefs.hiddenColumns?.[kind] || [];
+  return key => !hidden.includes(key);
+}
+
+function showColumns(list) {
+  const entry = COLUMNS.get(list);
+  if (!entry) return;
+  const { kind, columns } = entry;
+  const show = columnShown(kind);
+  const initial = columns.map(([key], i) => show(key) ? i : -1).filter(i => i !== -1);
+  pickMany(`Columns: ${PANEL_TITLES.get(list)}`, columns.map(([, label]) => label), async chosen => {
+    const hidden = columns.filter((_, i) => !chosen.includes(i)).map(([key]) => key);
+    state.prefs.hiddenColumns = { ...state.prefs.hiddenColumns, [kind]: hidden };
+    savePrefs();
+    await Promise.all([updateContainers(), updateImages(true), updateVolumes(true), updateNetworks(true)]);
+    screen.render();
+  }, initial);
+}
+
+// ==================== SEARCH ====================
+const PANEL_TITLES = new Map([
+  [ui.containersBox, "[2]-Containers"],
+  [ui.imagesBox, "[3]-Images"],
+  [ui.volumesBox, "[4]-Volumes"],
+  [ui.networksBox, "[5]-Networks"],
+]);
+
+function applySearch(rows) {
+  if (!state.search) return rows;
+  return rows.filter(row => rowSummary(row).toLowerCase().includes(state.search));
+}
+
+function updatePanelLabel(list, shown, total) {
+  const title = `${PANEL_TITLES.get(list)} (${total})`;
+  const mode = SORT_MODES.get(list)?.[state.sortMode.get(list) || 0];
+  const sort = mode?.compare ? ` {gray-fg}↕${mode.name}{/gray-fg}` : "";
+  const scope = list === ui.containersBox && state.prefs.hideStopped ? " {gray-fg}running only{/gray-fg}" : "";
+  const suffix = `${scope}${sort}`;
+  list.setLabel(state.search ? ` ${title}${suffix} {yellow-fg}[${shown}/${total} match]{/yellow-fg} ` : ` ${title}${suffix} `);
+}
+
+async function toggleStopped() {
+  state.prefs.hideStopped = !state.prefs.hideStopped;
+  savePrefs();
+  notify(state.prefs.hideStopped ? "Showing running containers only" : "Showing all containers", "cyan");
+  await updateContainers();
+  const visible = new Set(state.containers.map(c => c.name));
+  [...state.markedContainers].forEach(name => !visible.has(name) && state.markedContainers.delete(name));
+  await updateCurrentTab();
+  screen.render();
+}
+
+// ==================== SORTING ====================
+const SORT_MODES = new Map([
+  [ui.containersBox, [
+    { name: "default" },
+    { name: "uptime", compare: (a, b) => {
+      const sa = parseStatus(a.status), sb = parseStatus(b.status);
+      if (sa.running !== sb.running) return sa.running ? -1 : 1;
+      // Longest running first; among stopped, most recently exited first
+      return sa.running ? sb.sinceMs - sa.sinceMs : sa.sinceMs - sb.sinceMs;
+    } },
+    { name: "name", compare: (a, b) => a.name.localeCompare(b.name) },
+    { name: "size", compare: (a, b) => parseDockerSize((b.size || "").split(" ")[0]) - parseDockerSize((a.size || "").split(" ")[0]) },
+  ]],
+  [ui.imagesBox, [
+    { name: "default" },
+    { name: "size", compare: (a, b) => parseDockerSize(b.size) - parseDockerSize(a.size) },
+    { name: "name", compare: (a, b) => `${a.repo}:${a.tag}`.localeCompare(`${b.repo}:${b.tag}`) },
+  ]],
+  [ui.volumesBox, [
+    { name: "default" },
+    { name: "size", compare: (a, b) => parseDockerSize(b.size) - parseDockerSize(a.size) },
+  ]],
+]);
+
+function sortRows(list, rows) {
+  const mode = SORT_MODES.get(list)?.[state.sortMode.get(list) || 0];
+  const sorted = mode?.compare ? [...rows].sort(mode.compare) : rows;
+  return [...sorted.filter(row => isPinned(list, row)), ...sorted.filter(row => !isPinned(list, row))];
+}
+
+// ==================== PINNED ====================
+// Pins are kept by name (containers) or repo:tag (images, ID when untagged). A pin
+// whose item is gone stays saved and applies again if the item comes back.
+const PIN_PREFS = new Map([
+  [ui.containersBox, { key: "pinnedContainers", id: c => c.name }],
+  [ui.imagesBox, { key: "pinnedImages", id: img => img.repo === "<none>" ? img.id : `${img.repo}:${img.tag}` }],
+]);
+
+function isPinned(list, row) {
+  const pin = PIN_PREFS.get(list);
+  return !!pin && (state.prefs[pin.key] || []).includes(pin.id(row));
+}
+
+function pinStar(list, row) {
+  return isPinned(list, row) ? "{yellow-fg}★{/yellow-fg}" : " ";
+}
+
+async function togglePin(list, row) {
+  const pin = PIN_PREFS.get(list);
+  if (!pin || !row) return;
+  const id = pin.id(row);
+  const pinned = state.prefs[pin.key] || [];
+  state.prefs[pin.key] = pinned.includes(id) ? pinned.filter(p => p !== id) : [...pinned, id];
+  savePrefs();
+  notify(pinned.includes(id) ? `Unpinned ${id}` : `Pinned ${id}`, "yellow");
+  if (list === ui.containersBox) await updateContainers();
+  else await updateImages(true);
+  screen.render();
+}
+
+async function cycleSort(list) {
+  const modes = SORT_MODES.get(list);
+  if (!modes) return;
+  const next = ((state.sortMode.get(list) || 0) + 1) % modes.length;
+  state.sortMode.set(list, next);
+  notify(`Sort: ${modes[next].name}`, "cyan");
+  await Promise.all([updateContainers(), updateImages(true), updateVolumes(true), updateNetworks(true)]);
+  screen.render();
+}
+
+async function setSearch(term) {
+  state.search = term.toLowerCase();
+  await Promise.all([updateContainers(), updateImages(true), updateVolumes(true), updateNetworks(true)]);
+  await updateCurrentTab();
+  screen.render();
+}
+
+async function updateAll() {
+  state.env = {};
+  state.config = {};
+  state.top = {};
+  await Promise.all([updateContainers(), updateImages(), updateVolumes(), updateNetworks()]);
+  await updateCurrentTab();
+  screen.render();
+}
+
+// ==================== TAB CONTENT ====================
+function updateLogsTab() {
+  const c = state.containers[state.selectedContainerIndex];
+  ui.contentBox.setContent(c ? (visibleLogs() || "{gray-fg}No logs yet...{/gray-fg}") : "{yellow-fg}No container selected{/yellow-fg}");
+  screen.render();
+}
+
+function updateStatsTab() {
+  const c = state.containers[state.selectedContainerIndex];
+  if (!c) {
+    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
+    screen.render();
+    return;
+  }
+  
+  const st = state.stats[c.name] || {};
+  const running = c.state === "running";
+  
+  if (!state.cpuHistory[c.name]) state.cpuHistory[c.name] = [0];
+  if (!state.memHistory[c.name]) state.memHistory[c.name] = [0];
+  
+  let out = `{bold}{cyan-fg}Stats: ${c.name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(60)}{/gray-fg}\n\n`;
+  
+  if (!running) {
+    out += "{gray-fg}Container is not running\nPress [s] to start{/gray-fg}\n";
+  } else {
+    out += smoothChart(state.cpuHistory[c.name], 12, 55, "cyan", "CPU:   ") + "\n\n";
+    out += smoothChart(state.memHistory[c.name], 12, 55, "green", "Memory:") + "\n\n";
+    
+    const [rx, tx] = (st.netIO || "0B / 0B").split(" / ");
+    const [read, write] = (st.blockIO || "0B / 0B").split(" / ");
+    const parseBytes = s => parseFloat(s.match(/^([\d.]+)/)?.[1] || 0);
+    
+    out += `{bold}{yellow-fg}PIDs:{/yellow-fg}{/bold}     ${st.pids || "N/A"}\n`;
+    out += `{bold}{blue-fg}Net RX:{/blue-fg}{/bold}   ${humanBytes(parseBytes(rx))}\n`;
+    out += `{bold}{blue-fg}Net TX:{/blue-fg}{/bold}   ${humanBytes(parseBytes(tx))}\n`;
+    out += `{bold}{magenta-fg}Disk R:{/magenta-fg}{/bold}   ${humanBytes(parseBytes(read))}\n`;
+    out += `{bold}{magenta-fg}Disk W:{/magenta-fg}{/bold}   ${humanBytes(parseBytes(write))}\n`;
+    out += statsProcessesSection(c.name);
+  }
+  
+  ui.contentBox.setContent(out);
+  screen.render();
+}
+
+// Optional "top processes" section under the stats, from `docker top` with ps columns.
+// ps's %CPU is averaged over each process's lifetime, so it points at hogs rather than spikes
+const STATS_PROCS_TTL_MS = 5000;
+const STATS_PROCS_ROWS = 8;
+
+function statsProcessesSection(name) {
+  if (!state.prefs.statsProcesses) return "\n{gray-fg}[%] show top processes{/gray-fg}\n";
+  const procs = state.statsProcs;
+  if (procs.name !== name || Date.now() - procs.at > STATS_PROCS_TTL_MS) refreshStatsProcesses(name);
+  let out = `\n{bold}{cyan-fg}Top processes by CPU:{/cyan-fg}{/bold} {gray-fg}[%] hide{/gray-fg}\n`;
+  if (procs.name !== name) return out + "{gray-fg}Loading...{/gray-fg}\n";
+  if (procs.error) return out + `{gray-fg}${blessed.escape(procs.error)}{/gray-fg}\n`;
+  out += `{gray-fg}${"PID".padEnd(8)}${"CPU%".padStart(6)}${"MEM%".padStart(6)}${"RSS".padStart(10)}  COMMAND{/gray-fg}\n`;
+  for (const p of procs.rows.slice(0, STATS_PROCS_ROWS)) {
+    out += `${p.pid.padEnd(8)}{yellow-fg}${p.cpu.toFixed(1).padStart(6)}{/yellow-fg}${p.mem.toFixed(1).padStart(6)}${humanBytes(p.rss * 1024).padStart(10)}  ${blessed.escape(p.args.slice(0, 60))}\n`;
+  }
+  return out;
+}
+
+async function refreshStatsProcesses(name) {
+  if (state.statsProcs.loading) return;
+  state.statsProcs.loading = true;
+  const out = await dockerExec(`top ${name} -eo pid,pcpu,pmem,rss,args`, 10000);
+  const rows = (out || "").split("\n").slice(1).map(line => {
+    const [pid, cpu, mem, rss, ...args] = line.trim().split(/\s+/);
+    return { pid, cpu: parseFloat(cpu) || 0, mem: parseFloat(mem) || 0, rss: parseInt(rss, 10) || 0, args: args.join(" ") };
+  }).filter(p => p.pid);
+  rows.sort((a, b) => b.cpu - a.cpu || b.mem - a.mem);
+  state.statsProcs = { name, at: Date.now(), rows, loading: false, error: out === null ? "docker top failed (container stopped, or ps options unsupported)" : null };
+  if (state.currentTab === 1 && state.containers[state.selectedContainerIndex]?.name === name) updateStatsTab();
+}
+
+function toggleStatsProcesses() {
+  state.prefs.statsProcesses = !state.prefs.statsProcesses;
+  savePrefs();
+  if (state.currentTab === 1) updateStatsTab();
+}
+
+// Whole-host summary built from the last refresh plus the running stats stream
+function updateOverviewTab() {
+  const running = state.containers.filter(c => c.state === "running");
+  const usage = running.map(c => state.stats[c.name]).filter(Boolean);
+  const cpu = usage.reduce((sum, st) => sum + st.cpu, 0);
+  const mem = usage.reduce((sum, st) => sum + parseDockerSize(st.memUsage.split(" / ")[0]), 0);
+  const imageSize = state.images.reduce((sum, img) => sum + parseDockerSize(img.size), 0);
+  const row = (label, value) => `{bold}${label.padEnd(12)}{/bold}${value}\n`;
+  
+  let out = `{bold}{cyan-fg}Overview${state.search ? ` (matching "${state.search}")` : ""}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(60)}{/gray-fg}\n\n`;
+  out += row("Containers", `${state.containers.length}  {green-fg}${running.length} running{/green-fg}  {gray-fg}${state.containers.length - running.length} stopped{/gray-fg}`);
+  out += row("Images", `${state.images.length}  {gray-fg}${humanBytes(imageSize)} (shared layers counted per image){/gray-fg}`);
+  out += row("Volumes", String(state.volumes.length));
+  out += row("Networks", String(state.networks.length));
+  out += `\n{bold}{yellow-fg}Running containers{/yellow-fg}{/bold}\n`;
+  out += row("CPU", `${cpu.toFixed(1)}%`);
+  out += row("Memory", humanBytes(mem));
+  
+  const top = running.filter(c => state.stats[c.name]).sort((a, b) => state.stats[b.name].cpu - state.stats[a.name].cpu).slice(0, 5);
+  if (top.length) {
+    out += `\n{bold}{yellow-fg}Busiest{/yellow-fg}{/bold}\n`;
+    top.forEach(c => out += `  ${c.name.slice(0, 30).padEnd(32)}${state.stats[c.name].cpu.toFixed(1).padStart(6)}%  ${state.stats[c.name].memUsage}\n`);
+  }
+  
+  ui.contentBox.setContent(out);
+  screen.render();
+}
+
+async function updateEnvTab() {
+  const c = state.containers[state.selectedContainerIndex];
+  if (!c) {
+    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
+    return;
+  }
+  
+  if (state.env[c.name]) {
+    renderEnv(c.name, state.env[c.name]);
+    return;
+  }
+  
+  const envVars = await getContainerEnv(c.name);
+  state.env[c.name] = envVars;
+  renderEnv(c.name, envVars);
+}
+
+function renderEnv(name, envVars) {
+  let content = `{bold}{cyan-fg}Environment Variables: ${name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(55)}{/gray-fg}\n\n`;
+  if (envVars.length === 0) {
+    content += "{yellow-fg}No environment variables found{/yellow-fg}\n";
+  } else {
+    envVars.forEach(env => {
+      const eqIdx = env.indexOf("=");
+      if (eqIdx > 0) {
+        const key = env.substring(0, eqIdx);
+        const val = env.substring(eqIdx + 1);
+        content += `{bold}${key}{/bold}={green-fg}${val}{/green-fg}\n`;
+      } else {
+        content += `${env}\n`;
+      }
+    });
+  }
+  ui.contentBox.setContent(content);
+  screen.render();
+}
+
+async function updateConfigTab() {
+  const c = state.containers[state.selectedContainerIndex];
+  if (!c) {
+    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
+    return;
+  }
+  
+  if (state.config[c.name]) {
+    renderConfig(c.name, state.config[c.name]);
+    return;
+  }
+  
+  const inspect = await getContainerInspect(c.name);
+  state.config[c.name] = inspect;
+  renderConfig(c.name, inspect);
 }
 
 function renderConfig(name, inspect) {
@@ -655,171 +2968,1326 @@ function renderConfig(name, inspect) {
     content += `{bold}Cmd:{/bold} ${JSON.stringify(inspect.Config?.Cmd) || "N/A"}\n`;
     content += `{bold}WorkingDir:{/bold} ${inspect.Config?.WorkingDir || "/"}\n\n`;
     
-    content += `{bold}{yellow-fg}Network Settings:{/yellow-fg}{/bold}\n`;
-    const networks = inspect.NetworkSettings?.Networks || {};
-    for (const [netName, netConfig] of Object.entries(networks)) {
-      content += `  {bold}${netName}:{/bold}\n`;
-      content += `    IP: ${netConfig.IPAddress || "N/A"}\n`;
-      content += `    Gateway: ${netConfig.Gateway || "N/A"}\n`;
-      content += `    MAC: ${netConfig.MacAddress || "N/A"}\n`;
+    content += `{bold}{yellow-fg}Network Settings:{/yellow-fg}{/bold}\n`;
+    const networks = inspect.NetworkSettings?.Networks || {};
+    for (const [netName, netConfig] of Object.entries(networks)) {
+      content += `  {bold}${netName}:{/bold}\n`;
+      content += `    IP: ${netConfig.IPAddress || "N/A"}\n`;
+      content += `    Gateway: ${netConfig.Gateway || "N/A"}\n`;
+      content += `    MAC: ${netConfig.MacAddress || "N/A"}\n`;
+    }
+    content += "\n";
+    
+    content += `{bold}{green-fg}Port Bindings:{/green-fg}{/bold}\n`;
+    const ports = inspect.NetworkSettings?.Ports || {};
+    if (Object.keys(ports).length === 0) {
+      content += "  {gray-fg}No ports exposed{/gray-fg}\n";
+    } else {
+      for (const [containerPort, hostBindings] of Object.entries(ports)) {
+        if (hostBindings) {
+          hostBindings.forEach(binding => {
+            content += `  {cyan-fg}${binding.HostIp || "0.0.0.0"}:${binding.HostPort}{/cyan-fg} -> ${containerPort}\n`;
+          });
+        } else {
+          content += `  ${containerPort} (not bound)\n`;
+        }
+      }
+    }
+    content += "\n";
+    
+    content += `{bold}{magenta-fg}Mounts:{/magenta-fg}{/bold}\n`;
+    const mounts = inspect.Mounts || [];
+    if (mounts.length === 0) {
+      content += "  {gray-fg}No mounts{/gray-fg}\n";
+    } else {
+      mounts.forEach(mount => {
+        content += `  ${mount.Type}: ${mount.Source || "N/A"}\n`;
+        content += `    -> ${mount.Destination}\n`;
+      });
+    }
+    content += "\n";
+    
+    content += `{bold}{blue-fg}Labels:{/blue-fg}{/bold}\n`;
+    const labels = Object.entries(inspect.Config?.Labels || {});
+    if (labels.length === 0) {
+      content += "  {gray-fg}No labels{/gray-fg}\n";
+    } else {
+      labels.forEach(([key, val]) => {
+        content += `  {bold}${blessed.escape(key)}{/bold}=${blessed.escape(val)}\n`;
+      });
+    }
+    content += "\n";
+    
+    content += `{bold}{red-fg}Resource Limits:{/red-fg}{/bold}\n`;
+    const hostConfig = inspect.HostConfig || {};
+    content += `  CPU Shares: ${hostConfig.CpuShares || "default"}\n`;
+    content += `  CPUs: ${hostConfig.NanoCpus ? +(hostConfig.NanoCpus / 1e9).toFixed(2) : "unlimited"}\n`;
+    content += `  Memory Limit: ${hostConfig.Memory ? (hostConfig.Memory / 1024 / 1024).toFixed(0) + "MB" : "unlimited"}\n`;
+    content += `  Restart Policy: ${hostConfig.RestartPolicy?.Name || "no"}\n`;
+  }
+  ui.contentBox.setContent(content);
+  screen.render();
+}
+
+async function updateTopTab() {
+  const c = state.containers[state.selectedContainerIndex];
+  if (!c) {
+    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
+    return;
+  }
+  
+  if (state.top[c.name]) {
+    renderTop(c.name, state.top[c.name]);
+    return;
+  }
+  
+  const topInfo = c.state === "running" ? await getContainerTop(c.name) : "Container is not running";
+  state.top[c.name] = topInfo;
+  renderTop(c.name, topInfo);
+}
+
+function renderTop(name, topInfo) {
+  let content = `{bold}{cyan-fg}Top Processes: ${name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(55)}{/gray-fg}\n\n`;
+  const c = state.containers[state.selectedContainerIndex];
+  content += c?.state === "running" ? `{green-fg}${topInfo}{/green-fg}\n\n` : "{gray-fg}Container is not running{/gray-fg}\n\n";
+  ui.contentBox.setContent(content);
+  screen.render();
+}
+
+async function updateCurrentTab() {
+  if (state.currentTab === 5) {
+    stopLogStream();
+    updateOverviewTab();
+    return;
+  }
+  
+  const c = state.containers[state.selectedContainerIndex];
+  
+  if (!c && state.containers.length === 0) {
+    ui.contentBox.setContent("{yellow-fg}No containers available. Start Docker or create one.{/yellow-fg}");
+    screen.render();
+    return;
+  }
+  
+  if (!c) return;
+  
+  if (state.currentTab === 0 && (!state.logProcess || !state.logProcess.spawnargs?.includes(c.name))) {
+    showContainerLogs(c.name, "100");
+    return;
+  }
+  
+  if (state.currentTab !== 0) stopLogStream();
+  
+  const tabs = [updateLogsTab, updateStatsTab, updateEnvTab, updateConfigTab, updateTopTab, updateOverviewTab];
+  await tabs[state.currentTab]();
+  screen.render();
+}
+
+// ==================== UTILITIES ====================
+function logEvent(level, msg) {
+  writeLogFile(level, msg);
+  state.eventLog.push(`${new Date().toLocaleTimeString()} [${level}] ${msg}`);
+  if (state.eventLog.length > MAX_LOG_LINES) state.eventLog.shift();
+  if (state.prefs.statusDrawer) renderStatusDrawer();
+}
+
+// Wrap async callbacks (intervals, event handlers) so a throw is logged instead of crashing the app
+function safeAsync(label, fn) {
+  return async (...args) => {
+    try {
+      return await fn(...args);
+    } catch (error) {
+      logEvent("ERROR", `${label}: ${error.stack || error.message}`);
+      notify(`${label} failed: ${error.message}`, "red");
+    }
+  };
+}
+
+// ==================== RAW OUTPUT ====================
+const MAX_RAW_ENTRIES = 20;
+const MAX_RAW_CHARS = 4000;
+// One slot per background refresh command: the four lists, the restart-loop check and the details inspect
+const MAX_RAW_POLLS = 8;
+
+// Clipped when recorded, so a big `inspect` or `logs` doesn't sit in memory until it scrolls out
+const clipRaw = text => text.length > MAX_RAW_CHARS ? `${text.slice(0, MAX_RAW_CHARS)}\n… (${text.length - MAX_RAW_CHARS} more chars)` : text;
+
+function recordRawOutput(cmd, code, stdout = "", stderr = "", poll = false) {
+  const entry = { time: new Date().toLocaleTimeString(), cmd, code, stdout: clipRaw(stdout), stderr: clipRaw(stderr) };
+  if (poll) {
+    // The inspects end in container IDs, which change between runs, so they're keyed without them
+    const slot = cmd.replace(/( [0-9a-f]{12,64})+$/, "");
+    state.rawPolls.delete(slot);
+    state.rawPolls.set(slot, entry);
+    if (state.rawPolls.size > MAX_RAW_POLLS) state.rawPolls.delete(state.rawPolls.keys().next().value);
+    return;
+  }
+  state.rawOutput.push(entry);
+  if (state.rawOutput.length > MAX_RAW_ENTRIES) state.rawOutput.shift();
+}
+
+function showRawOutput() {
+  if (!state.prefs.rawOutput) {
+    notify("Turn on 'Record raw command output' in Settings (Shift+S) first", "yellow");
+    return;
+  }
+  const format = e => {
+    const color = e.code === 0 ? "green" : "red";
+    let out = `{bold}${e.time} $ ${blessed.escape(e.cmd)}{/bold} {${color}-fg}(exit ${e.code}){/${color}-fg}\n`;
+    if (e.stdout) out += `{gray-fg}stdout:{/gray-fg}\n${blessed.escape(e.stdout)}\n`;
+    if (e.stderr) out += `{yellow-fg}stderr:{/yellow-fg}\n${blessed.escape(e.stderr)}\n`;
+    return out;
+  };
+  const polls = [...state.rawPolls.values()].map(format);
+  const commands = state.rawOutput.map(format);
+  const sections = [
+    polls.length ? `{cyan-fg}{bold}Latest background refreshes{/bold}{/cyan-fg}\n\n${polls.join("\n")}` : "",
+    commands.length ? `{cyan-fg}{bold}Commands{/bold}{/cyan-fg}\n\n${commands.join("\n")}` : "",
+  ].filter(Boolean);
+  const dialog = showDialog("Raw Command Output", sections.join("\n") || "{gray-fg}No commands recorded yet{/gray-fg}", "magenta");
+  dialog.setScrollPerc(100);
+}
+
+function showEventLog() {
+  const colors = { ERROR: "red", WARN: "yellow" };
+  const lines = state.eventLog.map(line => {
+    const level = line.match(/\[(\w+)\]/)?.[1];
+    const color = colors[level];
+    return color ? `{${color}-fg}${blessed.escape(line)}{/${color}-fg}` : blessed.escape(line);
+  });
+  // Mouse support swallows plain drags, so native selection needs shift (option on macOS)
+  const header = "{gray-fg}[c] copy  [w] save  (or shift+drag to select text){/gray-fg}\n\n";
+  const dialog = showDialog("Event Log", header + (lines.join("\n") || "{gray-fg}No events yet{/gray-fg}"));
+  dialog.setScrollPerc(100);
+  const raw = state.eventLog.join("\n");
+  dialog.key(["c"], () => copyToClipboard(raw));
+  dialog.key(["w"], () => saveText(`nano-whale-events-${Date.now()}.log`, raw));
+}
+
+// The last failed operation can be replayed with "." until something else fails
+function notifyFailure(msg, retry) {
+  state.lastFailure = retry;
+  notify(`[.] retry | ${msg}`, "red");
+}
+
+function notify(msg, color = "green") {
+  logEvent(color === "red" ? "ERROR" : "INFO", msg);
+  const box = blessed.box({
+    top: "center", left: "center",
+    width: Math.min(msg.length + 6, 60), height: 3,
+    content: ` ${msg} `, border: { type: "line" },
+    style: { border: { fg: color }, fg: color, bg: "black" },
+  });
+  screen.append(box);
+  screen.render();
+  setTimeout(() => { screen.remove(box); screen.render(); }, 2000);
+}
+
+function showDialog(title, content, color = "cyan") {
+  const prevFocus = screen.focused;
+  const dialog = blessed.box({
+    parent: screen, top: "center", left: "center",
+    width: "80%", height: "80%",
+    label: ` ${title} `, border: { type: "line" },
+    style: { border: { fg: color }, label: { fg: color }, bg: "black" },
+    scrollable: true, alwaysScroll: true, keys: true, vi: true, mouse: true, tags: true,
+    scrollbar: { ch: "│", style: { fg: color } },
+    wrap: state.prefs.wrapLines !== false,
+    content: content + "\n\n{gray-fg}[Esc] close{/gray-fg}",
+  });
+  state.openDialogs++;
+  dialog.key(["escape", "enter"], () => {
+    setImmediate(() => { state.openDialogs--; });
+    dialog.destroy();
+    if (prevFocus) prevFocus.focus();
+    screen.render();
+  });
+  dialog.focus();
+  screen.render();
+  return dialog;
+}
+
+function promptInput(label, value, onSubmit) {
+  const prevFocus = screen.focused;
+  const prompt = blessed.prompt({
+    parent: screen, top: "center", left: "center",
+    width: 60, height: 8, border: { type: "line" },
+    style: { border: { fg: "cyan" }, fg: "white", bg: "black" },
+    tags: true,
+  });
+  state.openDialogs++;
+  prompt.input(label, value || "", (err, val) => {
+    setImmediate(() => { state.openDialogs--; });
+    prompt.destroy();
+    if (prevFocus) prevFocus.focus();
+    if (val !== null && val !== undefined) onSubmit(val.trim());
+    screen.render();
+  });
+}
+
+// Text input with suggestions from local images and pull history: tab completes the
+// highlighted one, up/down move the highlight, enter submits what's typed
+function promptImageRef(label, value, onSubmit) {
+  const prevFocus = screen.focused;
+  const candidates = imageRefSuggestions();
+  const box = blessed.box({
+    parent: screen, top: "center", left: "center",
+    width: 60, height: 16, label: ` ${label} `, border: { type: "line" },
+    style: { border: { fg: "cyan" }, label: { fg: "cyan" }, bg: "black" },
+    tags: true,
+  });
+  const input = blessed.textbox({ parent: box, top: 0, left: 1, right: 1, height: 1, value: value || "", style: { fg: "white", bg: "blue" } });
+  const list = blessed.list({
+    parent: box, top: 2, left: 1, right: 1, bottom: 1,
+    style: { fg: "gray", selected: { bg: "black", fg: "cyan", bold: true }, bg: "black" },
+  });
+  blessed.box({ parent: box, bottom: 0, right: 1, height: 1, width: 44, tags: true, content: "{gray-fg}[tab] complete [↑↓] choose [enter] ok{/gray-fg}" });
+  state.openDialogs++;
+  
+  let matches = [];
+  const refresh = () => {
+    const query = input.getValue().trim().toLowerCase();
+    matches = candidates.filter(c => c.toLowerCase().includes(query)).slice(0, 12);
+    list.setItems(matches.length ? matches : ["(no suggestions)"]);
+    list.select(0);
+    screen.render();
+  };
+  input.on("keypress", (ch, key) => {
+    if (key.name === "up") list.up();
+    else if (key.name === "down") list.down();
+    else if (key.name === "tab") {
+      // The textbox inserts the tab itself after this handler; overwrite it afterwards
+      const pick = matches[list.selected];
+      return setImmediate(() => {
+        input.setValue(pick ?? input.getValue().replace(/\t/g, ""));
+        refresh();
+      });
+    } else return setImmediate(refresh);
+    screen.render();
+  });
+  input.readInput((err, val) => {
+    setImmediate(() => { state.openDialogs--; });
+    box.destroy();
+    if (prevFocus) prevFocus.focus();
+    screen.render();
+    if (val !== null && val !== undefined) onSubmit(val.trim());
+  });
+  refresh();
+}
+
+function pickFromList(title, items, onPick) {
+  const prevFocus = screen.focused;
+  const list = blessed.list({
+    parent: screen, top: "center", left: "center",
+    width: 60, height: Math.min(items.length + 2, 20),
+    label: ` ${title} `, border: { type: "line" },
+    style: { border: { fg: "cyan" }, label: { fg: "cyan" }, selected: { bg: "blue", fg: "white", bold: true }, bg: "black" },
+    keys: true, vi: true, mouse: true, tags: true, items,
+  });
+  state.openDialogs++;
+  const close = () => {
+    setImmediate(() => { state.openDialogs--; });
+    list.destroy();
+    if (prevFocus) prevFocus.focus();
+    screen.render();
+  };
+  list.on("select", (item, idx) => {
+    close();
+    onPick(idx);
+  });
+  list.on("cancel", close);
+  list.focus();
+  screen.render();
+}
+
+// Multi-select list: space toggles, enter confirms with the chosen indices
+function pickMany(title, items, onDone, initial = []) {
+  const chosen = new Set(initial);
+  const prevFocus = screen.focused;
+  const render = () => items.map((item, i) => `${chosen.has(i) ? "[✓]" : "[ ]"} ${item}`);
+  const list = blessed.list({
+    parent: screen, top: "center", left: "center",
+    width: 60, height: Math.min(items.length + 3, 20),
+    label: ` ${title} `, border: { type: "line" },
+    style: { border: { fg: "cyan" }, label: { fg: "cyan" }, selected: { bg: "blue", fg: "white", bold: true }, bg: "black" },
+    keys: true, vi: true, mouse: true, tags: true, items: render(),
+  });
+  blessed.box({ parent: list, bottom: 0, right: 1, height: 1, width: 28, tags: true, content: "{gray-fg}[space] toggle [enter] ok{/gray-fg}" });
+  state.openDialogs++;
+  const close = () => {
+    setImmediate(() => { state.openDialogs--; });
+    list.destroy();
+    if (prevFocus) prevFocus.focus();
+    screen.render();
+  };
+  list.key(["space"], () => {
+    const idx = list.selected;
+    chosen.has(idx) ? chosen.delete(idx) : chosen.add(idx);
+    list.setItems(render());
+    list.select(idx);
+    screen.render();
+  });
+  list.on("select", () => {
+    close();
+    onDone([...chosen].sort((a, b) => a - b));
+  });
+  list.on("cancel", close);
+  list.focus();
+  screen.render();
+}
+
+// Skips the dialog when fewer than prefs.confirmThreshold items are affected (default 1: always confirm),
+// unless `always` is set for prompts that aren't about how many things get removed.
+// `items` lists exactly what will be affected in a scrollable area.
+// `action` labels the confirm button; anything other than "Yes" also titles the dialog with it and
+// is treated as destructive, so Enter starts on No and must be moved to the action deliberately
+function confirmCommand(prompt, args, onConfirm, { count = 1, items = [], action = "Yes", always = false } = {}) {
+  if (!always && count < (state.prefs.confirmThreshold ?? 1)) {
+    onConfirm();
+    return;
+  }
+  const cmd = `${dockerCmd} ${args.join(" ")}`;
+  const width = Math.max(50, Math.min(cmd.length + 8, 100));
+  const shown = cmd.length > width - 8 ? cmd.substring(0, width - 9) + "…" : cmd;
+  const promptLines = prompt.split("\n").length;
+  const listHeight = Math.min(items.length, 10);
+  const footerTop = promptLines + (listHeight ? listHeight + 1 : 0);
+  
+  const prevFocus = screen.focused;
+  const dialog = blessed.box({
+    parent: screen, top: "center", left: "center",
+    width, height: footerTop + 7, border: { type: "line" },
+    label: action === "Yes" ? undefined : ` {red-fg}{bold}${action}{/bold}{/red-fg} `,
+    style: { border: { fg: "red" }, fg: "white", bg: "black" },
+    tags: true,
+  });
+  blessed.box({ parent: dialog, top: 0, left: 1, right: 1, height: promptLines, tags: true, content: prompt });
+  const list = listHeight ? blessed.box({
+    parent: dialog, top: promptLines, left: 1, right: 1, height: listHeight,
+    style: { fg: "yellow" }, scrollable: true, alwaysScroll: true, keys: true, vi: true, mouse: true,
+    scrollbar: { ch: "│", style: { fg: "yellow" } },
+    content: items.map(item => `  • ${item}`).join("\n"),
+  }) : null;
+  blessed.box({ parent: dialog, top: footerTop, left: 1, right: 1, height: 2, tags: true, content: `{gray-fg}Run: ${shown}\n[c] copy command{/gray-fg}` });
+  const yesText = ` [y] ${action} `;
+  const yes = blessed.box({ parent: dialog, top: footerTop + 3, left: 2, width: yesText.length, height: 1, mouse: true, content: yesText, style: { bg: "red", fg: "white", bold: true } });
+  const no = blessed.box({ parent: dialog, top: footerTop + 3, left: yesText.length + 5, width: 8, height: 1, mouse: true, content: " [n] No ", style: { bg: "blue", fg: "white" } });
+  let selected = action === "Yes";
+  const highlight = () => {
+    yes.setContent((selected ? "▸" : " ") + yesText.slice(1));
+    no.setContent(selected ? " [n] No " : "▸[n] No ");
+    screen.render();
+  };
+  highlight();
+  
+  const finish = value => {
+    screen.removeListener("keypress", onKey);
+    // Defer so the key that answered the dialog doesn't also reach screen-level keys
+    setImmediate(() => { state.openDialogs--; });
+    dialog.destroy();
+    if (prevFocus) prevFocus.focus();
+    if (value) onConfirm();
+    screen.render();
+  };
+  const onKey = (ch, key) => {
+    if (key.name === "c") copyToClipboard(cmd);
+    else if (key.name === "y") finish(true);
+    else if (["enter", "return"].includes(key.name)) finish(selected);
+    else if (["n", "escape", "q"].includes(key.name)) finish(false);
+    else if (["left", "right", "tab"].includes(key.name)) {
+      selected = !selected;
+      highlight();
+    }
+  };
+  yes.on("click", () => finish(true));
+  no.on("click", () => finish(false));
+  screen.on("keypress", onKey);
+  state.openDialogs++;
+  (list || dialog).focus();
+  screen.render();
+}
+
+// Desktop notification for long operations that finish while you're in another window;
+// falls back to OSC 9, which iTerm2, Windows Terminal, kitty and others turn into one
+const LONG_OP_MS = 10000;
+
+function notifyDesktop(title, message) {
+  const plat = os.platform();
+  const [command, ...args] = plat === "darwin"
+    ? ["osascript", "-e", `display notification ${JSON.stringify(message)} with title ${JSON.stringify(title)}`]
+    : plat === "win32"
+      ? ["powershell", "-NoProfile", "-Command", [
+        "Add-Type -AssemblyName System.Windows.Forms",
+        "$n = New-Object System.Windows.Forms.NotifyIcon",
+        "$n.Icon = [System.Drawing.SystemIcons]::Information",
+        "$n.Visible = $true",
+        `$n.ShowBalloonTip(5000, '${title.replace(/'/g, "''")}', '${message.replace(/'/g, "''")}', 'Info')`,
+        "Start-Sleep -Seconds 6",
+        "$n.Dispose()",
+      ].join("; ")]
+      : ["notify-send", "-a", "nano-whale", title, message];
+  const child = spawn(command, args, { detached: true, stdio: "ignore" });
+  child.on("error", () => screen.program.output.write(`\x1b]9;${title}: ${message}\x07`));
+  child.unref();
+}
+
+// Only when enabled and the operation ran long enough that you've likely switched away
+function notifyIfLong(startedAt, ok, summary) {
+  if (!state.prefs.desktopNotify || Date.now() - startedAt < LONG_OP_MS) return;
+  notifyDesktop(`nano-whale: ${ok ? "done" : "failed"}`, summary);
+}
+
+function copyToClipboard(text) {
+  const plat = os.platform();
+  const candidates = plat === "win32" ? [["clip"]]
+    : plat === "darwin" ? [["pbcopy"]]
+    : [["wl-copy"], ["xclip", "-selection", "clipboard"], ["xsel", "--clipboard", "--input"]];
+  
+  for (const [command, ...args] of candidates) {
+    try {
+      if (plat !== "win32") execSync(`which ${command}`, { stdio: "ignore" });
+      const child = spawn(command, args, { stdio: ["pipe", "ignore", "ignore"] });
+      child.on("error", () => {});
+      child.stdin.end(text);
+      notify("Copied to clipboard", "green");
+      return;
+    } catch (_) {}
+  }
+  
+  // Fall back to OSC 52, which most modern terminals honour
+  screen.program.output.write(`\x1b]52;c;${Buffer.from(text).toString("base64")}\x07`);
+  notify("Copied to clipboard (terminal)", "green");
+}
+
+// Rows as currently listed (search and sort applied), for scripts and other tools
+function exportPanelJson() {
+  const panels = new Map([
+    [ui.containersBox, ["containers", state.containers]],
+    [ui.imagesBox, ["images", state.images]],
+    [ui.volumesBox, ["volumes", state.volumes]],
+    [ui.networksBox, ["networks", state.networks]],
+  ]);
+  const entry = panels.get(screen.focused);
+  if (!entry) return;
+  
+  // The panels hold only the rows matching the search, so say so when one is active
+  const [kind, rows] = entry;
+  const filter = state.search || undefined;
+  const file = filter ? `${kind}-filtered.json` : `${kind}.json`;
+  const json = JSON.stringify({ exportedAt: new Date().toISOString(), filter, [kind]: rows }, null, 2);
+  const title = `Export ${rows.length} ${filter ? `filtered ${kind} (matching "${filter}")` : kind} as JSON`;
+  pickFromList(title, ["Copy to clipboard", `Save to ${file}`], idx => {
+    if (idx === 0) copyToClipboard(json);
+    else saveText(file, json);
+  });
+}
+
+function cleanup() {
+  if (state.logProcess) try { state.logProcess.kill('SIGKILL'); } catch (_) {}
+  if (state.statsProcess) try { state.statsProcess.kill('SIGKILL'); } catch (_) {}
+  if (state.fullscreenChild) {
+    try { process.kill(-state.fullscreenChild.pid, 'SIGKILL'); } catch (_) {
+      try { state.fullscreenChild.kill('SIGKILL'); } catch (_) {}
+    }
+  }
+  if (state.containersInterval) clearInterval(state.containersInterval);
+  if (state.miscInterval) clearInterval(state.miscInterval);
+  if (state.pruneInterval) clearInterval(state.pruneInterval);
+  stopFileWatcher();
+  closeLogWindows();
+  if (state.backoff) clearTimeout(state.backoff.timer);
+}
+
+// Pop-out log terminals (ctrl + l) die with the app; their `logs -f` goes with them
+function closeLogWindows() {
+  for (const child of state.logWindows) {
+    try { process.kill(-child.pid, "SIGTERM"); } catch (_) {
+      try { child.kill("SIGTERM"); } catch (_) {}
+    }
+  }
+  state.logWindows.clear();
+}
+
+// Emergency stop: kill log/stats streams and every in-flight docker command (F5 resumes streams)
+function stopAllStreams() {
+  const stopped = [];
+  if (state.logProcess) {
+    stopLogStream();
+    stopped.push("log stream");
+  }
+  if (state.statsProcess) {
+    state.streamsStopped = true;
+    try { state.statsProcess.kill("SIGKILL"); } catch (_) {}
+    state.statsProcess = null;
+    stopped.push("stats stream");
+  }
+  for (const [child, label] of activeChildren) {
+    stoppedChildren.add(child);
+    try { child.kill("SIGKILL"); } catch (_) {}
+    logEvent("WARN", `Killed: ${label}`);
+  }
+  if (activeChildren.size) stopped.push(`${activeChildren.size} command(s)`);
+  activeChildren.clear();
+  
+  const summary = stopped.length ? `Stopped ${stopped.join(", ")}` : "Nothing to stop";
+  logEvent("WARN", summary);
+  notify(`${summary} — F5 resumes`, "yellow");
+}
+
+// Timers don't run while the machine sleeps, so a tick arriving far later than 3s means we just
+// woke up; the WSL/docker connection is often dead by then
+const SLEEP_GAP_MS = 30000;
+// `ps -s` can take far longer than a tick, so with sizes on the list is refreshed less often
+const SIZES_POLL_MS = 30000;
+
+function startPolling() {
+  state.lastPollAt = Date.now();
+  state.containersInterval = setInterval(safeAsync("Container refresh", async () => {
+    const gap = Date.now() - state.lastPollAt;
+    state.lastPollAt = Date.now();
+    if (gap > SLEEP_GAP_MS) {
+      logEvent("INFO", `No refresh for ${Math.round(gap / 1000)}s (resumed from sleep?); reconnecting`);
+      return reconnect();
+    }
+    if (state.backoff) return;
+    // Skip the listing while the previous one is still running instead of piling up `ps` processes
+    const due = !state.prefs.containerSizes || Date.now() - state.lastContainersPollAt >= SIZES_POLL_MS;
+    if (!state.containersPolling && due) {
+      state.containersPolling = true;
+      state.lastContainersPollAt = Date.now();
+      try {
+        await updateContainers();
+      } finally {
+        state.containersPolling = false;
+      }
+      // Two failed listings in a row rather than one, so a single slow `ps` doesn't count
+      if (state.psFailures >= 2) return startBackoff();
+    }
+    if (state.currentTab === 1) updateStatsTab();
+    if (state.currentTab === 5) updateOverviewTab();
+    screen.render();
+  }), 3000);
+  state.miscInterval = setInterval(safeAsync("Refresh", async () => {
+    await Promise.all([updateImages(), updateVolumes(), updateNetworks(), checkRestartLoops()]);
+    screen.render();
+  }), 15000);
+}
+
+// ==================== RESTART LOOPS ====================
+// RestartCount only grows on policy restarts, so a fast climb means the container keeps crashing.
+// Defaults: more than 3 restarts within 2 minutes; alerts once until the container calms down.
+async function checkRestartLoops() {
+  const ids = await dockerExec("ps -aq", 5000, { poll: true });
+  if (!ids) return;
+  const out = await dockerExec(`inspect --format "{{.Name}}|{{.RestartCount}}" ${ids.split("\n").join(" ")}`, 10000, { poll: true });
+  if (!out) return;
+  
+  const now = Date.now();
+  const windowMs = (state.prefs.loopWindowMin || 2) * 60000;
+  const limit = state.prefs.loopRestarts || 3;
+  const seen = {};
+  out.split("\n").forEach(line => {
+    const [rawName, count] = line.split("|");
+    const name = rawName.replace(/^\//, "");
+    const history = (state.restartHistory[name] || []).filter(h => now - h.at <= windowMs);
+    history.push({ at: now, count: parseInt(count, 10) || 0 });
+    seen[name] = history;
+    
+    const restarts = history[history.length - 1].count - history[0].count;
+    if (restarts > limit && !state.loopAlerted.has(name)) {
+      state.loopAlerted.add(name);
+      logEvent("WARN", `${name} restarted ${restarts} times in ${windowMs / 60000} min — likely a crash loop`);
+      notify(`Crash loop: ${name} restarted ${restarts}x in ${windowMs / 60000} min (Shift+B for crash logs)`, "red");
+    } else if (restarts === 0) {
+      state.loopAlerted.delete(name);
     }
-    content += "\n";
+  });
+  state.restartHistory = seen;
+}
+
+// ==================== DISK SPACE ====================
+const DISK_CHECK_TTL_MS = 60000;
+
+// A daemon reached over tcp/ssh, via -H, DOCKER_HOST or the current context. Its root dir may
+// also exist here (e.g. /var/lib/docker on a host with docker installed), but it's not its disk
+async function isRemoteDaemon() {
+  const local = host => !host || /^(unix|npipe):\/\//.test(host);
+  const flag = dockerCmd.match(/(?:^|\s)(?:-H|--host)(?:\s+|=)(\S+)/);
+  if (flag) return !local(flag[1]);
+  const envHost = state.prefs.dockerEnv?.DOCKER_HOST ?? process.env.DOCKER_HOST;
+  if (envHost) return !local(envHost);
+  return !local(await dockerExec('context inspect --format "{{.Endpoints.docker.Host}}"', 10000));
+}
+
+// Free space where the daemon stores its data; null when that path isn't on this machine
+// (Docker Desktop VM, remote daemon), in which case the check is skipped
+async function dockerFreeBytes() {
+  if (await isRemoteDaemon()) return null;
+  const root = await dockerExec('info --format "{{.DockerRootDir}}"', 10000);
+  if (!root) return null;
+  try {
+    const stats = fs.statfsSync(root);
+    return stats.bavail * stats.bsize;
+  } catch (_) {
+    return null;
+  }
+}
+
+// Best-effort warning before pulls, builds and runs; never blocks or fails the operation
+async function checkDiskSpace() {
+  const thresholdGb = state.prefs.lowDiskGb || 0;
+  if (!thresholdGb || Date.now() - state.diskCheckedAt < DISK_CHECK_TTL_MS) return;
+  state.diskCheckedAt = Date.now();
+  const free = await dockerFreeBytes();
+  if (free === null || free >= thresholdGb * 1e9) return;
+  const out = await dockerExec('system df --format "{{.Reclaimable}}"', 30000);
+  const reclaimable = (out || "").split("\n").reduce((sum, line) => sum + parseDockerSize(line.split(" ")[0]), 0);
+  const hint = reclaimable ? `, ${humanBytes(reclaimable)} reclaimable` : "";
+  logEvent("WARN", `Low disk space: ${humanBytes(free)} free for docker${hint}`);
+  notify(`Low disk: ${humanBytes(free)} free${hint} — Shift+Z to prune`, "yellow");
+}
+
+// ==================== PRUNE ====================
+const PRUNE_TARGETS = [
+  { label: "Stopped containers", args: ["container", "prune", "-f"] },
+  { label: "Dangling images", args: ["image", "prune", "-f"] },
+  { label: "All unused images", args: ["image", "prune", "-a", "-f"] },
+  { label: "Unused volumes", args: ["volume", "prune", "-f"] },
+  { label: "Unused networks", args: ["network", "prune", "-f"] },
+  { label: "Build cache", args: ["builder", "prune", "-f"] },
+  { label: "System (stopped containers, unused networks, dangling images)", args: ["system", "prune", "-f"] },
+  { label: "System, including all unused images", args: ["system", "prune", "-a", "-f"] },
+];
+
+function reclaimedSpace(output) {
+  return output.match(/Total(?: reclaimed space)?:\s*(.+)/)?.[1]?.trim() || "0B";
+}
+
+// Output streams into a dialog; closing it kills the prune
+function runPrune(target) {
+  return queueOperation(`Prune ${target.label}`, async () => {
+    const started = Date.now();
+    let child = null;
+    const append = showOutputDialog(`Prune: ${target.label}`, "red", () => {
+      if (child && child.exitCode === null) child.kill();
+    });
+    append(`$ docker ${target.args.join(" ")}\n(closing this dialog cancels)\n\n`);
     
-    content += `{bold}{green-fg}Port Bindings:{/green-fg}{/bold}\n`;
-    const ports = inspect.NetworkSettings?.Ports || {};
-    if (Object.keys(ports).length === 0) {
-      content += "  {gray-fg}No ports exposed{/gray-fg}\n";
+    let output = "";
+    const code = await streamDocker(target.args, chunk => {
+      output += chunk;
+      append(chunk);
+    }, c => child = c);
+    
+    if (child?.killed) {
+      notify(`Prune cancelled: ${target.label}`, "yellow");
+    } else if (code === 0) {
+      append(`\n✓ Reclaimed ${reclaimedSpace(output)}\n`);
+      notify(`Pruned ${target.label}: reclaimed ${reclaimedSpace(output)}`, "green");
+      notifyIfLong(started, true, `Pruned ${target.label}: reclaimed ${reclaimedSpace(output)}`);
     } else {
-      for (const [containerPort, hostBindings] of Object.entries(ports)) {
-        if (hostBindings) {
-          hostBindings.forEach(binding => {
-            content += `  {cyan-fg}${binding.HostIp || "0.0.0.0"}:${binding.HostPort}{/cyan-fg} -> ${containerPort}\n`;
-          });
-        } else {
-          content += `  ${containerPort} (not bound)\n`;
-        }
-      }
+      append(`\n✗ Prune failed (exit ${code})\n`);
+      notify(`Prune failed: ${target.label}`, "red");
+      notifyIfLong(started, false, `Prune failed: ${target.label}`);
     }
-    content += "\n";
+    await updateAll();
+  });
+}
+
+function showPrune() {
+  const items = PRUNE_TARGETS.map(t => `${t.label.padEnd(60)} {gray-fg}${t.args.slice(0, 2).join(" ")}{/gray-fg}`);
+  items.push("{red-fg}Reset environment: delete all containers, volumes, networks...{/red-fg}");
+  pickFromList("Prune", items, idx => {
+    if (idx === PRUNE_TARGETS.length) return showResetEnvironment();
+    const target = PRUNE_TARGETS[idx];
+    const confirm = t => confirmCommand(`Permanently remove ${t.label.toLowerCase()}?`, t.args, () => runPrune(t), { always: true, action: "Prune" });
+    const pinned = state.prefs.pinnedImages || [];
+    if (!target.args.includes("-a") || pinned.length === 0) return confirm(target);
     
-    content += `{bold}{magenta-fg}Mounts:{/magenta-fg}{/bold}\n`;
-    const mounts = inspect.Mounts || [];
-    if (mounts.length === 0) {
-      content += "  {gray-fg}No mounts{/gray-fg}\n";
-    } else {
-      mounts.forEach(mount => {
-        content += `  ${mount.Type}: ${mount.Source || "N/A"}\n`;
-        content += `    -> ${mount.Destination}\n`;
-      });
+    // prune has no name filter, so pinned images are protected by skipping -a
+    pickFromList(`${pinned.length} pinned image(s) would be removed if unused`, [
+      "Keep pinned images: prune dangling images only",
+      "Prune all unused images, including pinned",
+    ], choice => confirm(choice === 1 ? target : {
+      label: `${target.label.replace(/,? including all unused images|All unused images/i, "").trim() || "Dangling images"} (pinned kept)`,
+      args: target.args.filter(a => a !== "-a"),
+    }));
+  });
+}
+
+// ==================== RESET ENVIRONMENT ====================
+const RESET_PHRASE = "reset everything";
+const SYSTEM_NETWORKS = ["bridge", "host", "none"];
+
+async function resetEnvironment(plan) {
+  return queueOperation("Reset environment", async () => {
+    const append = showOutputDialog("Reset Environment", "red");
+    const results = [];
+    for (const step of plan) {
+      append(`\n=== ${step.label} (${step.names.length}) ===\n`);
+      const code = await streamDocker([...step.args, ...step.names], append);
+      results.push(`${code === 0 ? "✓" : "✗"} ${step.label}: ${step.names.length}`);
     }
-    content += "\n";
+    append(`\n=== Summary ===\n${results.join("\n")}\n`);
+    state.markedContainers.clear();
+    state.markedImages.clear();
+    state.markedVolumes.clear();
+    notify("Environment reset finished", results.some(r => r.startsWith("✗")) ? "yellow" : "green");
+    await updateAll();
+  });
+}
+
+// Lists everything first, then requires the confirm dialog and a typed phrase
+function showResetEnvironment() {
+  pickFromList("Reset environment", ["Containers, volumes and networks (keep images)", "Everything, including all images"], async choice => {
+    const [containers, volumes, networks, images] = await Promise.all([
+      dockerExec('ps -a --format "{{.Names}}"'),
+      dockerExec('volume ls -q'),
+      dockerExec('network ls --format "{{.Name}}"'),
+      choice === 1 ? dockerExec("images -q") : "",
+    ]);
+    const names = out => [...new Set((out || "").split("\n").filter(Boolean))];
+    const plan = [
+      { label: "Remove containers", kind: "container", args: ["rm", "-f"], names: names(containers) },
+      { label: "Remove volumes", kind: "volume", args: ["volume", "rm", "-f"], names: names(volumes) },
+      { label: "Remove networks", kind: "network", args: ["network", "rm"], names: names(networks).filter(n => !SYSTEM_NETWORKS.includes(n)) },
+      { label: "Remove images", kind: "image", args: ["rmi", "-f"], names: names(images) },
+    ].filter(step => step.names.length > 0);
     
-    content += `{bold}{red-fg}Resource Limits:{/red-fg}{/bold}\n`;
-    const hostConfig = inspect.HostConfig || {};
-    content += `  CPU Shares: ${hostConfig.CpuShares || "default"}\n`;
-    content += `  Memory Limit: ${hostConfig.Memory ? (hostConfig.Memory / 1024 / 1024).toFixed(0) + "MB" : "unlimited"}\n`;
-    content += `  Restart Policy: ${hostConfig.RestartPolicy?.Name || "no"}\n`;
-  }
-  ui.contentBox.setContent(content);
-  screen.render();
+    if (plan.length === 0) {
+      notify("Nothing to remove", "green");
+      return;
+    }
+    const summary = plan.map(step => `${step.names.length} ${step.kind}(s)`).join(", ");
+    const items = plan.flatMap(step => step.names.map(name => `${step.kind.padEnd(9)} ${name}`));
+    const args = plan.flatMap((step, i) => [...(i ? ["&&", ...splitCommand(dockerCmd)] : []), ...step.args, ...step.names]);
+    
+    confirmCommand(`{red-fg}{bold}Permanently delete ${summary}?{/bold}{/red-fg}`, args, () => {
+      promptInput(`Type "${RESET_PHRASE}" to confirm:`, "", phrase => {
+        if (phrase !== RESET_PHRASE) {
+          notify("Confirmation phrase didn't match; nothing was removed", "yellow");
+          return;
+        }
+        resetEnvironment(plan);
+      });
+    }, { always: true, count: items.length, items, action: "Continue" });
+  });
 }
 
-async function updateTopTab() {
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c) {
-    ui.contentBox.setContent("{yellow-fg}No container selected{/yellow-fg}");
-    return;
-  }
+// ==================== SCHEDULED PRUNE ====================
+// Off by default; prefs.pruneIntervalHours > 0 prunes at startup (if overdue) and then every N hours
+// Queued like any other mutation, so it never runs in the middle of a run, delete or build
+async function runScheduledPrune() {
+  const steps = [["system", "prune", "-f"]];
+  if (state.prefs.pruneBuildCache) steps.push(["builder", "prune", "-f"]);
   
-  if (state.top[c.name]) {
-    renderTop(c.name, state.top[c.name]);
-    return;
-  }
+  return queueOperation("Scheduled prune", async () => {
+    for (const args of steps) {
+      try {
+        const { stdout } = await execPromise(`${dockerCmd} ${args.join(" ")}`, { timeout: 300000 });
+        logEvent("INFO", `Scheduled ${args[0]} prune reclaimed ${reclaimedSpace(stdout)}`);
+      } catch (error) {
+        logEvent("ERROR", `Scheduled ${args[0]} prune failed: ${error.stderr?.trim() || error.message}`);
+      }
+    }
+    state.prefs.lastPrune = Date.now();
+    savePrefs();
+    await updateAll();
+  });
+}
+
+// A once-a-minute due check against lastPrune instead of a long setInterval: delays over
+// 2^31-1 ms (~596 h) are clamped to 1 ms and would prune in a tight loop
+const PRUNE_CHECK_MS = 60000;
+
+// enabling: called from Settings; turning the schedule on starts the clock now rather than
+// pruning straight away
+function schedulePrune(enabling = false) {
+  const wasOn = !!state.pruneInterval;
+  if (state.pruneInterval) clearInterval(state.pruneInterval);
+  state.pruneInterval = null;
+  const hours = state.prefs.pruneIntervalHours || 0;
+  if (hours <= 0) return;
+  if (enabling && !wasOn) state.prefs.lastPrune = Date.now();
   
-  const topInfo = c.state === "running" ? await getContainerTop(c.name) : "Container is not running";
-  state.top[c.name] = topInfo;
-  renderTop(c.name, topInfo);
+  let running = false;
+  const check = safeAsync("Scheduled prune", async () => {
+    if (running || Date.now() - (state.prefs.lastPrune || 0) < hours * 3600 * 1000) return;
+    running = true;
+    try { await runScheduledPrune(); } finally { running = false; }
+  });
+  state.pruneInterval = setInterval(check, PRUNE_CHECK_MS);
+  check();
 }
 
-function renderTop(name, topInfo) {
-  let content = `{bold}{cyan-fg}Top Processes: ${name}{/cyan-fg}{/bold}\n{gray-fg}${"─".repeat(55)}{/gray-fg}\n\n`;
-  const c = state.containers[state.selectedContainerIndex];
-  content += c?.state === "running" ? `{green-fg}${topInfo}{/green-fg}\n\n` : "{gray-fg}Container is not running{/gray-fg}\n\n";
-  ui.contentBox.setContent(content);
-  screen.render();
+// ==================== SETTINGS ====================
+function numberSetting(key, label, onChange) {
+  return done => promptInput(label, String(state.prefs[key] ?? ""), val => {
+    const n = Number(val || 0);
+    if (!Number.isFinite(n) || n < 0) {
+      notify(`Invalid number: ${val}`, "red");
+      return;
+    }
+    state.prefs[key] = n;
+    if (onChange) onChange();
+    done();
+  });
 }
 
-async function updateCurrentTab() {
-  const c = state.containers[state.selectedContainerIndex];
+function choiceSetting(key, label, choices, fallback) {
+  return done => pickFromList(label, choices, idx => {
+    state.prefs[key] = choices[idx] === fallback ? undefined : choices[idx];
+    done();
+  });
+}
+
+function toggleSetting(key, onChange) {
+  return done => {
+    state.prefs[key] = !state.prefs[key];
+    if (onChange) onChange();
+    done();
+  };
+}
+
+function showDockerEnv(done) {
+  const env = state.prefs.dockerEnv || {};
+  const keys = Object.keys(env);
+  const items = keys.map(k => `${k}={cyan-fg}${env[k]}{/cyan-fg}`);
+  items.push("{cyan-fg}+ Add variable...{/cyan-fg}");
   
-  if (!c && state.containers.length === 0) {
-    ui.contentBox.setContent("{yellow-fg}No containers available. Start Docker or create one.{/yellow-fg}");
-    screen.render();
+  pickFromList("Docker environment (Enter edits; empty value removes)", items, idx => {
+    const edit = key => promptInput(`Value for ${key} (empty removes):`, env[key] || "", value => {
+      const next = { ...env };
+      if (value) next[key] = value;
+      else delete next[key];
+      state.prefs.dockerEnv = Object.keys(next).length ? next : undefined;
+      done();
+    });
+    if (idx < keys.length) return edit(keys[idx]);
+    promptInput("Variable name (e.g. DOCKER_BUILDKIT):", "", key => {
+      if (!/^[A-Za-z_][A-Za-z0-9_]*$/.test(key)) {
+        if (key) notify(`Invalid variable name: ${key}`, "red");
+        return done();
+      }
+      edit(key);
+    });
+  });
+}
+
+const SETTINGS = [
+  {
+    label: "Docker command",
+    value: () => dockerCmd,
+    edit: done => promptInput("Docker command prefix (e.g. docker, sudo docker, wsl -d Debian docker):", dockerCmd, async val => {
+      await applyDockerCmd(val);
+      if (dockerCmd === val) state.prefs.activeProfile = undefined;
+      updateDeviceBox();
+      done();
+    }),
+  },
+  {
+    label: "Profile",
+    value: () => state.prefs.activeProfile || "none",
+    edit: showProfiles,
+  },
+  {
+    label: "Backend",
+    value: () => isPodman() ? "podman" : "docker",
+    edit: done => pickFromList("Backend", ["docker", "podman"], async idx => {
+      await applyDockerCmd(idx === 1 ? "podman" : DEFAULT_DOCKER_CMD);
+      done();
+    }),
+  },
+  {
+    label: "Docker environment",
+    value: () => Object.keys(state.prefs.dockerEnv || {}).join(", ") || "none",
+    edit: showDockerEnv,
+  },
+  {
+    label: "Registry mirrors",
+    value: () => "view",
+    edit: done => showRegistryMirrors().then(dialog => dialog.on("destroy", () => setImmediate(done))),
+  },
+  {
+    label: "Check prerequisites",
+    value: () => "",
+    edit: done => showPrerequisites(() => done()),
+  },
+  {
+    label: "Test a docker command",
+    value: () => "",
+    edit: done => promptInput("Docker command to test (e.g. docker -H ssh://user@host, docker --context prod):", dockerCmd, async val => {
+      if (val) await testDockerCmd(val);
+      done();
+    }),
+  },
+  {
+    label: "Confirm when removing ≥ N items",
+    value: () => String(state.prefs.confirmThreshold ?? 1),
+    edit: numberSetting("confirmThreshold", "Ask for confirmation when removing at least N items (1 = always):"),
+  },
+  {
+    label: "Show stopped containers",
+    value: () => state.prefs.hideStopped ? "off" : "on",
+    edit: done => toggleStopped().then(done),
+  },
+  {
+    label: "Compact view",
+    value: () => state.prefs.compact ? "on" : "off",
+    edit: toggleSetting("compact", async () => {
+      applyLayout();
+      await Promise.all([updateContainers(), updateImages(true), updateVolumes(true)]);
+    }),
+  },
+  {
+    label: "Follow logs after run",
+    value: () => state.prefs.followAfterRun === false ? "off" : "on",
+    edit: done => {
+      state.prefs.followAfterRun = state.prefs.followAfterRun === false;
+      done();
+    },
+  },
+  {
+    label: "Staggered restart delay (s)",
+    value: () => String(state.prefs.restartStaggerSec ?? 5),
+    edit: numberSetting("restartStaggerSec", "Seconds between restarts when restarting marked containers staggered:"),
+  },
+  {
+    label: "Wrap long lines",
+    value: () => state.prefs.wrapLines === false ? "off" : "on",
+    edit: done => toggleWrap().then(done),
+  },
+  {
+    label: "Log timestamps",
+    value: () => state.prefs.logTimestamps || "off",
+    edit: done => pickFromList("Log timestamps", ["off", "utc", "local"], idx => {
+      state.prefs.logTimestamps = idx === 0 ? undefined : ["off", "utc", "local"][idx];
+      const c = state.containers[state.selectedContainerIndex];
+      if (c && state.currentTab === 0) showContainerLogs(c.name, "100");
+      done();
+    }),
+  },
+  {
+    label: "Container sizes",
+    value: () => state.prefs.containerSizes ? "on" : "off",
+    edit: toggleSetting("containerSizes", () => updateContainers()),
+  },
+  {
+    label: "Volume sizes",
+    value: () => state.prefs.volumeSizes ? "on" : "off",
+    edit: toggleSetting("volumeSizes", () => {
+      state.volumeSizes.at = 0;
+      updateVolumes(true);
+    }),
+  },
+  {
+    label: "Crash loop: restarts",
+    value: () => `> ${state.prefs.loopRestarts || 3} in ${state.prefs.loopWindowMin || 2} min`,
+    edit: numberSetting("loopRestarts", "Warn when a container restarts more than N times within the window (0 = default 3):"),
+  },
+  {
+    label: "Crash loop: window (min)",
+    value: () => String(state.prefs.loopWindowMin || 2),
+    edit: numberSetting("loopWindowMin", "Crash loop detection window in minutes (0 = default 2):"),
+  },
+  {
+    label: "Desktop notifications",
+    value: () => state.prefs.desktopNotify ? "on" : "off",
+    edit: toggleSetting("desktopNotify"),
+  },
+  {
+    label: "Build progress output",
+    value: () => state.prefs.buildProgress ?? "plain",
+    edit: choiceSetting("buildProgress", "Build progress (--progress)", ["plain", "auto", "off"], "plain"),
+  },
+  {
+    label: "Log to file",
+    value: () => state.prefs.logToFile ? LOG_FILE : "off",
+    edit: toggleSetting("logToFile"),
+  },
+  {
+    label: "Debug logging",
+    value: () => state.prefs.debugLog ? "on" : "off",
+    edit: toggleSetting("debugLog"),
+  },
+  {
+    label: "Record raw command output",
+    value: () => state.prefs.rawOutput ? "on (F12)" : "off",
+    edit: toggleSetting("rawOutput", () => { state.rawOutput = []; state.rawPolls.clear(); }),
+  },
+  {
+    label: "Log file max size (KB)",
+    value: () => String(state.prefs.logMaxKB || 1024),
+    edit: numberSetting("logMaxKB", "Rotate the log file after N KB:"),
+  },
+  {
+    label: "Low disk warning (GB, 0=off)",
+    value: () => state.prefs.lowDiskGb ? `below ${state.prefs.lowDiskGb}GB` : "off",
+    edit: numberSetting("lowDiskGb", "Warn before pull/build/run when docker has less than N GB free (0 = off):", () => { state.diskCheckedAt = 0; }),
+  },
+  {
+    label: "Scheduled prune (hours, 0=off)",
+    value: () => state.prefs.pruneIntervalHours ? `every ${state.prefs.pruneIntervalHours}h` : "off",
+    edit: numberSetting("pruneIntervalHours", "Run 'system prune -f' every N hours (0 = off):", () => schedulePrune(true)),
+  },
+  {
+    label: "Scheduled prune: build cache",
+    value: () => state.prefs.pruneBuildCache ? "on" : "off",
+    edit: toggleSetting("pruneBuildCache"),
+  },
+];
+
+function showSettings() {
+  const items = SETTINGS.map(s => `${s.label.padEnd(34)} {cyan-fg}${s.value()}{/cyan-fg}`);
+  pickFromList("Settings", items, idx => {
+    SETTINGS[idx].edit(() => {
+      savePrefs();
+      showSettings();
+    });
+  });
+}
+
+// ==================== FULLSCREEN ====================
+function enterFullscreen() {
+  state.inFullscreenMode = true;
+  if (state.containersInterval) clearInterval(state.containersInterval);
+  if (state.miscInterval) clearInterval(state.miscInterval);
+  stopLogStream();
+  if (state.statsProcess) try { state.statsProcess.kill(); } catch (_) {}
+  
+  screen.lockKeys = true;
+  screen.program.showCursor();
+  screen.program.disableMouse();
+  screen.program.clear();
+  screen.program.normalBuffer();
+  screen.program.input.pause();
+  screen.program.output.write("\x1b[?1049l\x1b[?25h");
+  if (process.stdin.setRawMode) process.stdin.setRawMode(false);
+}
+
+async function exitFullscreen() {
+  if (process.stdin.setRawMode) process.stdin.setRawMode(true);
+  state.inFullscreenMode = false;
+  screen.lockKeys = false;
+  screen.program.output.write("\x1b[?1049h");
+  screen.program.input.resume();
+  screen.program.alternateBuffer();
+  screen.program.enableMouse();
+  screen.program.hideCursor();
+  screen.alloc();
+  screen.realloc();
+  ui.containersBox.focus();
+  updateTabHeader();
+  await updateAll();
+  startStatsStream();
+  startPolling();
+  const cur = state.containers[state.selectedContainerIndex];
+  if (state.currentTab === 0 && cur) showContainerLogs(cur.name, "100");
+  screen.render();
+}
+
+function execShell(c, execOpts) {
+  if (!c || c.state !== "running") {
+    notify("Container must be running", "red");
     return;
   }
+  rememberContainer(c);
+  enterFullscreen();
   
-  if (!c) return;
-  
-  if (state.currentTab === 0 && (!state.logProcess || !state.logProcess.spawnargs?.includes(c.name))) {
-    showContainerLogs(c.name, "100");
+  setTimeout(() => {
+    const shellCmd = shellCommand(c.name, execOpts);
+    process.stdout.write('\r\n🐳 Entering shell in ' + c.name + '...\r\n📋 Press Ctrl+D to return\r\n\r\n');
+    
+    const child = spawn(shellCmd, [], { stdio: "inherit", shell: true, env: dockerEnv() });
+    state.fullscreenChild = child;
+    
+    child.on("exit", code => {
+      state.fullscreenChild = null;
+      setTimeout(async () => {
+        await exitFullscreen();
+        if (code === 126 || code === 127) notify(`No usable shell in ${c.name} (tried ${shellChain().join(", ")})`, "red");
+      }, 100);
+    });
+  }, 100);
+}
+
+function fullscreenLogs(c) {
+  if (!c || c.state !== "running") {
+    notify("Container must be running", "red");
     return;
   }
+  rememberContainer(c);
+  enterFullscreen();
   
-  if (state.currentTab !== 0) stopLogStream();
-  
-  const tabs = [updateLogsTab, updateStatsTab, updateEnvTab, updateConfigTab, updateTopTab];
-  await tabs[state.currentTab]();
-  screen.render();
+  setTimeout(() => {
+    const cmdParts = [...splitCommand(dockerCmd), 'logs', '-f', c.name];
+    if (process.stdin.setRawMode) process.stdin.setRawMode(true);
+    process.stdin.resume();
+    
+    const child = spawn(cmdParts[0], cmdParts.slice(1), { stdio: ["ignore", "inherit", "inherit"], detached: !isWindows, env: dockerEnv() });
+    state.fullscreenChild = child;
+    
+    const onData = key => {
+      if (key[0] === 0x04) {
+        try { process.kill(-child.pid, 'SIGKILL'); } catch (_) { child.kill('SIGKILL'); }
+      }
+    };
+    process.stdin.on('data', onData);
+    
+    child.on("exit", () => {
+      state.fullscreenChild = null;
+      process.stdin.removeListener('data', onData);
+      setTimeout(exitFullscreen, 100);
+    });
+  }, 100);
+}
+
+// ==================== FILE WATCH (EXPERIMENTAL) ====================
+const WATCH_DEBOUNCE_MS = 1000;
+
+function stopFileWatcher() {
+  if (!state.fileWatcher) return;
+  clearTimeout(state.fileWatcher.timer);
+  try { state.fileWatcher.watcher.close(); } catch (_) {}
+  state.fileWatcher = null;
+}
+
+// Recursive watching isn't available everywhere; fall back to the top-level directory
+function watchDir(dir, onChange) {
+  try {
+    return fs.watch(dir, { recursive: true }, (_, file) => onChange(file));
+  } catch (_) {
+    return fs.watch(dir, (_, file) => onChange(file));
+  }
+}
+
+function showWatchRestart(c) {
+  promptInput(`[experimental] Restart ${c.name} when files change in:`, process.cwd(), dir => {
+    if (!dir) return;
+    dir = path.resolve(dir);
+    if (!fs.existsSync(dir) || !fs.statSync(dir).isDirectory()) {
+      notify(`Not a directory: ${dir}`, "red");
+      return;
+    }
+    
+    stopFileWatcher();
+    const header = `{bold}Watching{/bold} ${dir}\n{bold}Restarts{/bold}  ${c.name} (after ${WATCH_DEBOUNCE_MS / 1000}s without changes)\n\n{yellow-fg}Experimental. Closing this dialog stops watching.{/yellow-fg}\n\n`;
+    const lines = [];
+    const dialog = showDialog("Restart on Change", header, "yellow");
+    const render = () => {
+      dialog.setContent(header + lines.slice(-50).join("\n") + "\n\n{gray-fg}[Esc] stop and close{/gray-fg}");
+      screen.render();
+    };
+    
+    const watch = { watcher: null, timer: null, changed: new Set() };
+    watch.watcher = watchDir(dir, file => {
+      if (file && /(^|[\\/])\.git([\\/]|$)/.test(file)) return;
+      if (file) watch.changed.add(file);
+      clearTimeout(watch.timer);
+      watch.timer = setTimeout(() => {
+        const files = [...watch.changed];
+        watch.changed.clear();
+        lines.push(`${new Date().toLocaleTimeString()}  ${files.slice(0, 3).join(", ")}${files.length > 3 ? ` +${files.length - 3} more` : ""} → restart`);
+        render();
+        restartContainer(c.name);
+      }, WATCH_DEBOUNCE_MS);
+    });
+    watch.watcher.on("error", error => {
+      lines.push(`{red-fg}Watcher error: ${error.message}{/red-fg}`);
+      render();
+      stopFileWatcher();
+    });
+    state.fileWatcher = watch;
+    dialog.on("destroy", stopFileWatcher);
+    render();
+  });
 }
 
-// ==================== UTILITIES ====================
-function notify(msg, color = "green") {
-  const box = blessed.box({
-    top: "center", left: "center",
-    width: Math.min(msg.length + 6, 60), height: 3,
-    content: ` ${msg} `, border: { type: "line" },
-    style: { border: { fg: color }, fg: color, bg: "black" },
-  });
-  screen.append(box);
-  screen.render();
-  setTimeout(() => { screen.remove(box); screen.render(); }, 2000);
+// ==================== RECENT CONTAINERS ====================
+const MAX_RECENT = 5;
+
+function rememberContainer(c) {
+  const recent = (state.prefs.recentContainers || []).filter(r => r.id !== c.id && r.name !== c.name);
+  recent.unshift({ id: c.id, name: c.name });
+  state.prefs.recentContainers = recent.slice(0, MAX_RECENT);
+  savePrefs();
 }
 
-function confirmDelete(prompt, onConfirm) {
-  const dialog = blessed.question({
-    parent: screen, top: "center", left: "center",
-    width: 50, height: 7, border: { type: "line" },
-    style: { border: { fg: "red" }, fg: "white", bg: "black" },
-  });
-  dialog.ask(prompt, (err, value) => {
-    if (value) onConfirm();
-    screen.render();
-  });
+function findRecent(r) {
+  return state.containers.find(c => c.id === r.id) || state.containers.find(c => c.name === r.name);
 }
 
-function cleanup() {
-  if (state.logProcess) try { state.logProcess.kill('SIGKILL'); } catch (_) {}
-  if (state.statsProcess) try { state.statsProcess.kill('SIGKILL'); } catch (_) {}
-  if (state.fullscreenChild) {
-    try { process.kill(-state.fullscreenChild.pid, 'SIGKILL'); } catch (_) {
-      try { state.fullscreenChild.kill('SIGKILL'); } catch (_) {}
-    }
+function showRecentContainers() {
+  const recent = state.prefs.recentContainers || [];
+  if (recent.length === 0) {
+    notify("No recent containers yet", "yellow");
+    return;
   }
-  if (state.containersInterval) clearInterval(state.containersInterval);
-  if (state.miscInterval) clearInterval(state.miscInterval);
+  
+  const items = recent.map(r => findRecent(r) ? r.name : `{gray-fg}${r.name} (removed){/gray-fg}`);
+  pickFromList("Recent Containers", items, idx => {
+    const c = findRecent(recent[idx]);
+    if (!c) {
+      notify(`${recent[idx].name} no longer exists`, "yellow");
+      state.prefs.recentContainers = recent.filter((_, i) => i !== idx);
+      savePrefs();
+      return;
+    }
+    
+    ui.containersBox.focus();
+    ui.containersBox.select(state.containers.indexOf(c));
+    state.selectedContainerIndex = ui.containersBox.selected;
+    pickFromList(c.name, ["Select", "Logs", "Shell", "Logs in new window", "Shell in new window"], action => {
+      if (action === 1) fullscreenLogs(c);
+      else if (action === 2) execShell(c);
+      else if (action === 3) spawnNewWindow(`${dockerCmd} logs -f ${c.name}`, `logs-${c.name}`);
+      else if (action === 4) spawnNewWindow(shellCommand(c.name), `exec-${c.name}`);
+    });
+  });
 }
 
 // ==================== KEYBOARD HANDLERS ====================
+// The panels are vi-mode lists, which move their selection on j/k (with or without Shift), g/G,
+// Shift+H/M/L and Ctrl+B/D/F/U after these handlers run, so none of those are bound here
 screen.key(["q", "C-c"], () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   cleanup();
   process.exit(0);
 });
 
-screen.key(["F5"], () => !state.inFullscreenMode && updateAll());
+screen.key(["F5"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  if (state.streamsStopped) {
+    startStatsStream();
+    notify("Streams resumed", "green");
+  }
+  updateAll();
+});
+screen.key(["C-x"], () => !state.inFullscreenMode && stopAllStreams());
+screen.key(["C-r"], () => !state.inFullscreenMode && !state.openDialogs && reconnect());
 
 screen.key(["right"], async () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   state.currentTab = (state.currentTab + 1) % TAB_NAMES.length;
   updateTabHeader();
   await updateCurrentTab();
 });
 
 screen.key(["left"], async () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   state.currentTab = (state.currentTab - 1 + TAB_NAMES.length) % TAB_NAMES.length;
   updateTabHeader();
   await updateCurrentTab();
 });
 
-screen.key(["2"], () => !state.inFullscreenMode && ui.containersBox.focus() && screen.render());
-screen.key(["3"], () => !state.inFullscreenMode && ui.imagesBox.focus() && screen.render());
-screen.key(["4"], () => !state.inFullscreenMode && ui.volumesBox.focus() && screen.render());
-screen.key(["5"], () => !state.inFullscreenMode && ui.networksBox.focus() && screen.render());
+screen.key(["2"], () => !state.inFullscreenMode && !state.openDialogs && ui.containersBox.focus() && screen.render());
+screen.key(["3"], () => !state.inFullscreenMode && !state.openDialogs && ui.imagesBox.focus() && screen.render());
+screen.key(["4"], () => !state.inFullscreenMode && !state.openDialogs && ui.volumesBox.focus() && screen.render());
+screen.key(["5"], () => !state.inFullscreenMode && !state.openDialogs && ui.networksBox.focus() && screen.render());
 
 // Mark/unmark items
 screen.key(["m"], async () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   const f = screen.focused;
   
   if (f === ui.containersBox) {
@@ -844,9 +4312,41 @@ screen.key(["m"], async () => {
   screen.render();
 });
 
+// Select every row in the focused panel matching a regex (or plain substring)
+function selectByPattern(list) {
+  const panels = new Map([
+    [ui.containersBox, { rows: state.containers, marked: state.markedContainers, key: c => c.name, kind: "containers", refresh: updateContainers }],
+    [ui.imagesBox, { rows: state.images, marked: state.markedImages, key: img => img.id, kind: "images", refresh: () => updateImages(true) }],
+    [ui.volumesBox, { rows: state.volumes, marked: state.markedVolumes, key: v => v.name, kind: "volumes", refresh: () => updateVolumes(true) }],
+  ]);
+  const panel = panels.get(list);
+  if (!panel) return;
+  
+  promptInput(`Select ${panel.kind} matching (regex or text):`, "", async pattern => {
+    if (!pattern) return;
+    let test;
+    try {
+      const re = new RegExp(pattern, "i");
+      test = text => re.test(text);
+    } catch {
+      test = text => text.toLowerCase().includes(pattern.toLowerCase());
+    }
+    const matches = panel.rows.filter(row => test(rowSummary(row)));
+    matches.forEach(row => panel.marked.add(panel.key(row)));
+    notify(`Selected ${matches.length} ${panel.kind} (${panel.marked.size} marked)`, matches.length ? "green" : "yellow");
+    await panel.refresh();
+    screen.render();
+  });
+}
+
+screen.key(["+"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  selectByPattern(screen.focused);
+});
+
 // Select all
 screen.key(["C-a"], async () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   const f = screen.focused;
   
   if (f === ui.containersBox) {
@@ -882,7 +4382,7 @@ screen.key(["C-a"], async () => {
 
 // Container actions
 screen.key(["s"], async () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
   
   if (state.markedContainers.size > 0) {
     const containers = state.containers.filter(c => state.markedContainers.has(c.name));
@@ -906,61 +4406,69 @@ screen.key(["s"], async () => {
 });
 
 screen.key(["r"], async () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
   
   if (state.markedContainers.size > 0) {
     const containers = state.containers.filter(c => state.markedContainers.has(c.name) && c.state === "running");
-    if (containers.length > 0) {
-      notify(`Restarting ${containers.length} container(s)...`, "blue");
-      for (const c of containers) await restartContainer(c.name);
-    } else {
+    state.markedContainers.clear();
+    if (containers.length === 0) {
       notify("No running containers selected", "yellow");
+      await updateContainers();
+    } else if (containers.length === 1) {
+      await restartContainer(containers[0].name);
+    } else {
+      // Each restart refreshes the list when it finishes
+      const delay = state.prefs.restartStaggerSec ?? 5;
+      pickFromList(`Restart ${containers.length} containers`, ["All at once", `Staggered, ${delay}s apart (in list order)`], idx => {
+        restartMany(containers.map(c => c.name), idx === 1 ? delay : null);
+      });
     }
-    state.markedContainers.clear();
-    await updateContainers();
   } else {
     const c = state.containers[state.selectedContainerIndex];
     if (c && c.state === "running") await restartContainer(c.name);
   }
 });
 
+const KEEP_CONTAINER_HINT = "\n{gray-fg}To keep it, stop it instead ([s]).{/gray-fg}";
+
 // Delete
 screen.key(["d"], async () => {
-  if (state.inFullscreenMode) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
   const f = screen.focused;
   
   if (f === ui.containersBox) {
     if (state.markedContainers.size > 0) {
-      confirmDelete(`Delete ${state.markedContainers.size} container(s)?`, async () => {
+      confirmCommand(`Permanently delete ${state.markedContainers.size} container(s)?${KEEP_CONTAINER_HINT}`, ["rm", "-f", ...state.markedContainers], async () => {
         for (const name of state.markedContainers) await deleteContainer(name);
         state.markedContainers.clear();
         await updateContainers();
-      });
+      }, { count: state.markedContainers.size, items: [...state.markedContainers], action: "Delete" });
     } else {
       const c = state.containers[state.selectedContainerIndex];
-      if (c) confirmDelete(`Delete container ${c.name}?`, () => deleteContainer(c.name));
+      if (c) confirmCommand(`Permanently delete container ${c.name}?${KEEP_CONTAINER_HINT}`, ["rm", "-f", c.name], () => deleteContainer(c.name), { action: "Delete" });
     }
   } else if (f === ui.imagesBox) {
     if (state.markedImages.size > 0) {
-      confirmDelete(`Delete ${state.markedImages.size} image(s)?`, async () => {
+      confirmCommand(`Permanently delete ${state.markedImages.size} image(s)?`, ["rmi", "-f", ...state.markedImages], async () => {
         for (const id of state.markedImages) await deleteImage(id);
         state.markedImages.clear();
         await updateImages();
-      });
+      }, { count: state.markedImages.size, items: [...state.markedImages].map(imageLabel), action: "Delete" });
     } else {
       const img = state.images[state.selectedImageIndex];
-      if (img) confirmDelete(`Delete image ${img.repo}:${img.tag}?`, () => deleteImage(img.id));
+      if (img) confirmCommand(`Permanently delete image ${img.repo}:${img.tag}?`, ["rmi", "-f", img.id], () => deleteImage(img.id), { action: "Delete" });
     }
   } else if (f === ui.volumesBox) {
     if (state.markedVolumes.size > 0) {
-      confirmDelete(`Delete ${state.markedVolumes.size} volume(s)?`, async () => {
+      const warning = await volumeUsageWarning([...state.markedVolumes]);
+      confirmCommand(`Permanently delete ${state.markedVolumes.size} volume(s) and their data?${warning}`, ["volume", "rm", "-f", ...state.markedVolumes], async () => {
         for (const name of state.markedVolumes) await deleteVolume(name);
         state.markedVolumes.clear();
         await updateVolumes();
-      });
+      }, { count: state.markedVolumes.size, items: [...state.markedVolumes], action: "Delete" });
     } else {
       const vol = state.volumes[state.selectedVolumeIndex];
-      if (vol) confirmDelete(`Delete volume ${vol.name}?`, () => deleteVolume(vol.name));
+      if (vol) confirmCommand(`Permanently delete volume ${vol.name} and its data?${await volumeUsageWarning([vol.name])}`, ["volume", "rm", "-f", vol.name], () => deleteVolume(vol.name), { action: "Delete" });
     }
   } else if (f === ui.networksBox) {
     const net = state.networks[state.selectedNetworkIndex];
@@ -968,149 +4476,268 @@ screen.key(["d"], async () => {
       if (['bridge', 'host', 'none'].includes(net.name)) {
         notify(`Cannot delete '${net.name}' - system network`, "yellow");
       } else {
-        confirmDelete(`Delete network ${net.name}?`, () => deleteNetwork(net.name));
+        confirmCommand(`Permanently delete network ${net.name}?`, ["network", "rm", net.name], () => deleteNetwork(net.name), { action: "Delete" });
       }
     }
   }
 });
 
+screen.key(["x"], () => !state.inFullscreenMode && !state.openDialogs && exportPanelJson());
+
+// Show the full, untruncated row for the focused list
+screen.key(["enter"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  const rows = new Map([
+    [ui.containersBox, [state.containers, state.selectedContainerIndex, "Container"]],
+    [ui.imagesBox, [state.images, state.selectedImageIndex, "Image"]],
+    [ui.volumesBox, [state.volumes, state.selectedVolumeIndex, "Volume"]],
+    [ui.networksBox, [state.networks, state.selectedNetworkIndex, "Network"]],
+  ]);
+  if (screen.focused === ui.networksBox) {
+    const net = state.networks[state.selectedNetworkIndex];
+    if (net) showNetworkInspect(net.name);
+    return;
+  }
+  if (screen.focused === ui.volumesBox) {
+    const vol = state.volumes[state.selectedVolumeIndex];
+    if (vol) showVolumeDetails(vol);
+    return;
+  }
+  const entry = rows.get(screen.focused);
+  if (!entry) return;
+  const [items, idx, kind] = entry;
+  if (items[idx]) showDialog(`${kind} Details`, rowDetail(items[idx]));
+});
+
 // Exec into container (in-shell)
 screen.key(["t"], () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c || c.state !== "running") {
-    notify("Container must be running", "red");
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  execShell(state.containers[state.selectedContainerIndex]);
+});
+
+screen.key(["S-o"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.volumesBox) return;
+  const vol = state.volumes[state.selectedVolumeIndex];
+  if (vol) openVolumeLocation(vol);
+});
+
+screen.key(["S-u"], () => !state.inFullscreenMode && !state.openDialogs && showTemplates());
+
+screen.key(["="], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  if (state.markedContainers.size !== 2) {
+    notify("Mark exactly two containers (m) to compare", "yellow");
     return;
   }
-  
-  state.inFullscreenMode = true;
-  if (state.containersInterval) clearInterval(state.containersInterval);
-  if (state.miscInterval) clearInterval(state.miscInterval);
-  stopLogStream();
-  if (state.statsProcess) try { state.statsProcess.kill(); } catch (_) {}
-  
-  screen.lockKeys = true;
-  screen.program.showCursor();
-  screen.program.disableMouse();
-  screen.program.clear();
-  screen.program.normalBuffer();
-  screen.program.input.pause();
-  screen.program.output.write("\x1b[?1049l\x1b[?25h");
-  if (process.stdin.setRawMode) process.stdin.setRawMode(false);
-  
-  setTimeout(() => {
-    const shellCmd = isWindows ? `wsl docker exec -it ${c.name} sh -c "exec /bin/bash || exec /bin/sh"` : `docker exec -it ${c.name} sh -c "exec /bin/bash || exec /bin/sh"`;
-    process.stdout.write('\r\n🐳 Entering shell in ' + c.name + '...\r\n📋 Press Ctrl+D to return\r\n\r\n');
-    
-    const child = spawn(shellCmd, [], { stdio: "inherit", shell: true });
-    state.fullscreenChild = child;
-    
-    child.on("exit", () => {
-      state.fullscreenChild = null;
-      setTimeout(async () => {
-        if (process.stdin.setRawMode) process.stdin.setRawMode(true);
-        state.inFullscreenMode = false;
-        screen.lockKeys = false;
-        screen.program.output.write("\x1b[?1049h");
-        screen.program.input.resume();
-        screen.program.alternateBuffer();
-        screen.program.enableMouse();
-        screen.program.hideCursor();
-        screen.alloc();
-        screen.realloc();
-        ui.containersBox.focus();
-        updateTabHeader();
-        await updateAll();
-        startStatsStream();
-        state.containersInterval = setInterval(async () => {
-          await updateContainers();
-          if (state.currentTab === 1) updateStatsTab();
-          screen.render();
-        }, 3000);
-        state.miscInterval = setInterval(async () => {
-          await Promise.all([updateImages(), updateVolumes(), updateNetworks()]);
-          screen.render();
-        }, 15000);
-        const cur = state.containers[state.selectedContainerIndex];
-        if (state.currentTab === 0 && cur) showContainerLogs(cur.name, "100");
-        screen.render();
-      }, 100);
-    });
-  }, 100);
+  showCompare([...state.markedContainers]);
+});
+
+screen.key(["S-f"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  showSavedCommands(state.containers[state.selectedContainerIndex]);
+});
+
+screen.key(["h"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const c = state.containers[state.selectedContainerIndex];
+  if (c) showHealth(c);
+});
+
+screen.key(["f"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  if (screen.focused === ui.containersBox) togglePin(ui.containersBox, state.containers[state.selectedContainerIndex]);
+  else if (screen.focused === ui.imagesBox) togglePin(ui.imagesBox, state.images[state.selectedImageIndex]);
+});
+
+screen.key(["S-d"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  showDownloadLogs();
+});
+
+screen.key(["S-t"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  showExecAs(state.containers[state.selectedContainerIndex]);
 });
 
 // View logs (in-shell)
 screen.key(["l"], () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c || c.state !== "running") {
-    notify("Container must be running", "red");
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  fullscreenLogs(state.containers[state.selectedContainerIndex]);
+});
+
+// Connect/disconnect a container to the selected network
+screen.key(["c"], async () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.networksBox) return;
+  const net = state.networks[state.selectedNetworkIndex];
+  if (!net) return;
+  const connected = await networkContainers(net.name);
+  const candidates = state.containers.filter(c => !connected.includes(c.name));
+  if (candidates.length === 0) {
+    notify(`All containers are already connected to ${net.name}`, "yellow");
     return;
   }
-  
-  state.inFullscreenMode = true;
-  if (state.containersInterval) clearInterval(state.containersInterval);
-  if (state.miscInterval) clearInterval(state.miscInterval);
-  stopLogStream();
-  if (state.statsProcess) try { state.statsProcess.kill(); } catch (_) {}
-  
-  screen.lockKeys = true;
-  screen.program.showCursor();
-  screen.program.disableMouse();
-  screen.program.clear();
-  screen.program.normalBuffer();
-  screen.program.input.pause();
-  screen.program.output.write("\x1b[?1049l\x1b[?25h");
-  if (process.stdin.setRawMode) process.stdin.setRawMode(false);
-  
-  setTimeout(() => {
-    const cmdParts = isWindows ? ['wsl', 'docker', 'logs', '-f', c.name] : ['docker', 'logs', '-f', c.name];
-    if (process.stdin.setRawMode) process.stdin.setRawMode(true);
-    process.stdin.resume();
-    
-    const child = spawn(cmdParts[0], cmdParts.slice(1), { stdio: ["ignore", "inherit", "inherit"], detached: !isWindows });
-    state.fullscreenChild = child;
-    
-    const onData = key => {
-      if (key[0] === 0x04) {
-        try { process.kill(-child.pid, 'SIGKILL'); } catch (_) { child.kill('SIGKILL'); }
-      }
-    };
-    process.stdin.on('data', onData);
-    
-    child.on("exit", () => {
-      state.fullscreenChild = null;
-      process.stdin.removeListener('data', onData);
-      setTimeout(async () => {
-        if (process.stdin.setRawMode) process.stdin.setRawMode(true);
-        state.inFullscreenMode = false;
-        screen.lockKeys = false;
-        screen.program.output.write("\x1b[?1049h");
-        screen.program.input.resume();
-        screen.program.alternateBuffer();
-        screen.program.enableMouse();
-        screen.program.hideCursor();
-        screen.alloc();
-        screen.realloc();
-        ui.containersBox.focus();
-        updateTabHeader();
-        await updateAll();
-        startStatsStream();
-        state.containersInterval = setInterval(async () => {
-          await updateContainers();
-          if (state.currentTab === 1) updateStatsTab();
-          screen.render();
-        }, 3000);
-        state.miscInterval = setInterval(async () => {
-          await Promise.all([updateImages(), updateVolumes(), updateNetworks()]);
-          screen.render();
-        }, 15000);
-        const cur = state.containers[state.selectedContainerIndex];
-        if (state.currentTab === 0 && cur) showContainerLogs(cur.name, "100");
-        screen.render();
-      }, 100);
-    });
-  }, 100);
+  pickFromList(`Connect to ${net.name}`, candidates.map(c => c.name), idx => connectNetwork(net.name, candidates[idx].name));
+});
+
+screen.key(["u"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  checkImageUpdates();
+});
+
+screen.key(["u"], async () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.networksBox) return;
+  const net = state.networks[state.selectedNetworkIndex];
+  if (!net) return;
+  const connected = await networkContainers(net.name);
+  if (connected.length === 0) {
+    notify(`No containers connected to ${net.name}`, "yellow");
+    return;
+  }
+  pickFromList(`Disconnect from ${net.name}`, connected, idx => disconnectNetwork(net.name, connected[idx]));
+});
+
+screen.key(["n"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.networksBox) return;
+  showCreateNetwork();
+});
+
+screen.key(["."], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  if (!state.lastFailure) {
+    notify("Nothing to retry", "yellow");
+    return;
+  }
+  const retry = state.lastFailure;
+  state.lastFailure = null;
+  notify("Retrying...", "cyan");
+  retry();
+});
+
+screen.key(["f12"], () => !state.inFullscreenMode && !state.openDialogs && showRawOutput());
+
+screen.key(["S-z"], () => !state.inFullscreenMode && !state.openDialogs && showPrune());
+
+screen.key(["S-p"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showProfiles(savePrefs);
+});
+
+// Global search across all panels
+screen.key(["C-g"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  promptInput("Search containers, images, volumes, networks (empty to clear):", state.search, setSearch);
+});
+
+// Esc outside dialogs cancels a hanging WSL start, else clears the global search, then the log filter
+screen.key(["escape"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  if (state.wslWarmChild) {
+    state.wslWarmChild.kill();
+    notify("Cancelled WSL start; see the event log (Shift+E) to start it manually", "yellow");
+  } else if (state.search) {
+    notify("Search cleared", "yellow");
+    setSearch("");
+  } else if (state.logsFilter) {
+    setLogsFilter("");
+  }
+});
+
+// Toggle between `docker ps -a` and `docker ps`
+screen.key(["v"], async () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  await toggleStopped();
+});
+
+screen.key(["%"], () => !state.inFullscreenMode && !state.openDialogs && toggleStatsProcesses());
+
+screen.key(["C-o"], () => !state.inFullscreenMode && !state.openDialogs && showOpenFile());
+
+screen.key(["S-i"], () => !state.inFullscreenMode && !state.openDialogs && showDockerInfo());
+
+screen.key(["S-v"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showColumns(screen.focused);
+});
+
+screen.key(["o"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  cycleSort(screen.focused);
+});
+
+screen.key(["S-s"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showSettings();
+});
+
+screen.key(["e"], () => !state.inFullscreenMode && !state.openDialogs && toggleStatusDrawer());
+
+screen.key(["S-e"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showEventLog();
+});
+
+screen.key(["p"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  const img = state.images[state.selectedImageIndex];
+  img ? showPullRepository(img) : showPullImage();
+});
+
+screen.key(["r"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  const img = state.images[state.selectedImageIndex];
+  if (img) runImageDetached(img);
+});
+
+screen.key(["c"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  const img = state.images[state.selectedImageIndex];
+  if (img) createFromImage(img);
+});
+
+screen.key(["b"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  showBuildImage();
+});
+
+screen.key(["S-a"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.imagesBox) return;
+  const img = state.images[state.selectedImageIndex];
+  if (img) showImagePlatforms(img);
+});
+
+// Last 500 log lines, works on stopped containers
+screen.key(["S-b"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const c = state.containers[state.selectedContainerIndex];
+  if (c) showCrashLogs(c);
+});
+
+screen.key(["S-c"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showComposeLogs();
+});
+
+screen.key(["S-w"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const c = state.containers[state.selectedContainerIndex];
+  if (c) showWatchRestart(c);
+});
+
+screen.key(["S-x"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const c = state.containers[state.selectedContainerIndex];
+  if (c) showExportContainer(c);
+});
+
+screen.key(["i"], () => {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const c = state.containers[state.selectedContainerIndex];
+  if (c) showSendSignal(c);
+});
+
+screen.key(["S-r"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  showRecentContainers();
 });
 
 screen.key(["a"], () => {
@@ -1118,6 +4745,25 @@ screen.key(["a"], () => {
   notify(`Auto-scroll: ${state.logsAutoScroll ? "ON" : "OFF"}`, state.logsAutoScroll ? "green" : "yellow");
 });
 
+// Unwrapped lines are cut at the panel edge: one log entry per row, easier to scan
+async function toggleWrap() {
+  state.prefs.wrapLines = state.prefs.wrapLines === false;
+  savePrefs();
+  ui.contentBox.wrap = state.prefs.wrapLines;
+  ui.contentBox.setContent("");
+  notify(`Wrap lines: ${state.prefs.wrapLines ? "ON" : "OFF"}`, "cyan");
+  await updateCurrentTab();
+  screen.render();
+}
+
+screen.key(["w"], () => !state.inFullscreenMode && !state.openDialogs && toggleWrap());
+
+screen.key(["/"], () => {
+  if (state.inFullscreenMode || state.openDialogs) return;
+  const current = state.logsFilter ? state.logsFilter.source : "";
+  promptInput("Show only log lines matching (regex, empty to clear):", current, setLogsFilter);
+});
+
 screen.key(["pageup"], () => {
   state.logsAutoScroll = false;
   ui.contentBox.scroll(-10);
@@ -1131,37 +4777,51 @@ screen.key(["pagedown"], () => {
 
 // New terminal windows for exec and logs
 screen.key(["C-t"], () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
+  if (state.inFullscreenMode || state.openDialogs) return;
+  if (screen.focused === ui.imagesBox) {
+    const img = state.images[state.selectedImageIndex];
+    if (img) runImageInteractive(img);
+    return;
+  }
+  if (screen.focused !== ui.containersBox) return;
   const c = state.containers[state.selectedContainerIndex];
   if (!c || c.state !== "running") {
     notify("Container must be running", "red");
     return;
   }
   
-  const cmd = `${dockerCmd} exec -it ${c.name} sh -c "exec /bin/bash || exec /bin/sh"`;
+  rememberContainer(c);
+  const cmd = shellCommand(c.name);
   spawnNewWindow(cmd, `exec-${c.name}`);
 });
 
+// One window per marked container, or the selected one
 screen.key(["C-l"], () => {
-  if (state.inFullscreenMode || screen.focused !== ui.containersBox) return;
-  const c = state.containers[state.selectedContainerIndex];
-  if (!c || c.state !== "running") {
+  if (state.inFullscreenMode || state.openDialogs || screen.focused !== ui.containersBox) return;
+  const targets = state.markedContainers.size > 0
+    ? state.containers.filter(c => state.markedContainers.has(c.name) && c.state === "running")
+    : [state.containers[state.selectedContainerIndex]].filter(c => c && c.state === "running");
+  if (targets.length === 0) {
     notify("Container must be running", "red");
     return;
   }
   
-  const cmd = `${dockerCmd} logs -f ${c.name}`;
-  spawnNewWindow(cmd, `logs-${c.name}`);
+  for (const c of targets) {
+    rememberContainer(c);
+    spawnNewWindow(`${dockerCmd} logs -f ${c.name}`, `logs-${c.name}`, true);
+  }
 });
 
-function spawnNewWindow(cmd, label) {
+// track: close the window when nano-whale exits. Only possible where the terminal stays our child
+// (xterm, konsole, ...); Windows Terminal, cmd, Terminal.app and gnome-terminal hand off and return
+function spawnNewWindow(cmd, label, track = false) {
   const plat = os.platform();
   
   if (plat === "win32") {
     // Try Windows Terminal first
     try {
       execSync("where wt", { stdio: "ignore" });
-      exec(`wt new-tab --title "${label}" cmd /k ${cmd}`, (error) => {
+      exec(`wt new-tab --title "${label}" cmd /k ${cmd}`, { env: dockerEnv() }, (error) => {
         if (error) notify(`Failed to open Windows Terminal: ${error.message}`, "red");
       });
       notify(`Opened new tab in Windows Terminal`, "green");
@@ -1172,7 +4832,7 @@ function spawnNewWindow(cmd, label) {
     try {
       execSync("where mintty", { stdio: "ignore" });
       const bashPath = process.env.SHELL || "C:\\Program Files\\Git\\bin\\bash.exe";
-      exec(`mintty -t "${label}" -e ${bashPath} -c "${cmd}"`, (error) => {
+      exec(`mintty -t "${label}" -e ${bashPath} -c "${cmd}"`, { env: dockerEnv() }, (error) => {
         if (error) notify(`Failed to open Git Bash: ${error.message}`, "red");
       });
       notify(`Opened new Git Bash window`, "green");
@@ -1180,7 +4840,7 @@ function spawnNewWindow(cmd, label) {
     } catch (_) {}
     
     // Last resort: cmd.exe
-    exec(`start cmd /k ${cmd}`, (error) => {
+    exec(`start cmd /k ${cmd}`, { env: dockerEnv() }, (error) => {
       if (error) notify(`Failed to open cmd: ${error.message}`, "red");
     });
     notify(`Opened new cmd window`, "green");
@@ -1207,7 +4867,11 @@ function spawnNewWindow(cmd, label) {
     try {
       const [command, ...args] = term.split(" ");
       execSync(`which ${command}`, { stdio: "ignore" });
-      spawn(command, args, { detached: true, stdio: "ignore" });
+      const child = spawn(command, args, { detached: true, stdio: "ignore", env: dockerEnv() });
+      if (track) {
+        state.logWindows.add(child);
+        child.on("exit", () => state.logWindows.delete(child));
+      }
       notify(`Opened new terminal window`, "green");
       return;
     } catch (_) {
@@ -1222,18 +4886,40 @@ function spawnNewWindow(cmd, label) {
 process.on("SIGINT", () => { cleanup(); process.exit(0); });
 process.on("SIGTERM", () => { cleanup(); process.exit(0); });
 process.on("exit", cleanup);
+process.on("uncaughtException", error => {
+  logEvent("ERROR", `Uncaught: ${error.stack || error.message}`);
+  notify(`Unexpected error: ${error.message}`, "red");
+});
+process.on("unhandledRejection", reason => {
+  logEvent("ERROR", `Unhandled rejection: ${reason?.stack || reason}`);
+  notify(`Unexpected error: ${reason?.message || reason}`, "red");
+});
 
 ui.containersBox.focus();
+applyLayout();
 updateTabHeader();
 updateHelpBar();
 screen.render();
 
-(async () => {
+async function startup() {
   try {
-    await execPromise(`${dockerCmd} --version`, { timeout: 10000 });
+    if (usesWsl()) {
+      ui.contentBox.setContent("{yellow-fg}Starting WSL... {gray-fg}[Esc] cancel{/gray-fg}{/yellow-fg}");
+      screen.render();
+      await warmWsl();
+    }
+    try {
+      await execPromise(`${dockerCmd} --version`, { timeout: 10000 });
+    } catch (error) {
+      // No docker CLI and no saved choice: fall back to podman when it's installed
+      if (state.prefs.dockerCmd) throw error;
+      await execPromise("podman --version", { timeout: 10000 }).catch(() => { throw error; });
+      dockerCmd = "podman";
+      notify("docker not found; using podman", "yellow");
+    }
     await updateAll();
     
-    ui.containersBox.on("select item", async () => {
+    ui.containersBox.on("select item", safeAsync("Selection", async () => {
       state.selectedContainerIndex = ui.containersBox.selected;
       const c = state.containers[state.selectedContainerIndex];
       if (state.currentTab === 0 && c) {
@@ -1243,7 +4929,7 @@ screen.render();
       }
       updateHelpBar();
       screen.render();
-    });
+    }));
     
     ui.imagesBox.on("select item", () => {
       state.selectedImageIndex = ui.imagesBox.selected;
@@ -1261,24 +4947,21 @@ screen.render();
     });
     
     startStatsStream();
+    schedulePrune();
+    detectRootless();
     
     if (state.containers.length > 0) {
       showContainerLogs(state.containers[0].name, "100");
     }
     
-    state.containersInterval = setInterval(async () => {
-      await updateContainers();
-      if (state.currentTab === 1) updateStatsTab();
-      screen.render();
-    }, 3000);
-    
-    state.miscInterval = setInterval(async () => {
-      await Promise.all([updateImages(), updateVolumes(), updateNetworks()]);
-      screen.render();
-    }, 15000);
+    startPolling();
     
   } catch (error) {
     ui.contentBox.setContent(`{red-fg}Docker not accessible: ${error.message}{/red-fg}\n\nMake sure Docker is running.`);
     screen.render();
+    // Start over once the checklist comes back clean
+    showPrerequisites(passed => passed && startup());
   }
-})();
\ No newline at end of file
+}
+
+startup();
\ No newline at end of file
@@ -0,0 +1,29 @@
+// Pure parsers for docker CLI output, kept free of UI state so they can be tested on their own
+
+const SIZE_UNITS = { b: 1, kb: 1e3, mb: 1e6, gb: 1e9, tb: 1e12, kib: 1024, mib: 1024 ** 2, gib: 1024 ** 3, tib: 1024 ** 4 };
+
+// Docker's human sizes ("1.2GB", "900MB", "12.5kB") to bytes; 0 when unparseable
+function parseDockerSize(str) {
+  const m = String(str || "").trim().match(/^([\d.]+)\s*([kmgt]?i?b)$/i);
+  return m ? Math.round(parseFloat(m[1]) * (SIZE_UNITS[m[2].toLowerCase()] || 0)) : 0;
+}
+
+const DURATION_UNITS = { second: 1e3, minute: 6e4, hour: 36e5, day: 864e5, week: 6048e5, month: 2592e6, year: 31536e6 };
+
+// "5 minutes", "About an hour", "Less than a second" to milliseconds
+function parseDuration(str) {
+  const m = str.match(/(\d+|an?|less than a)\s+(second|minute|hour|day|week|month|year)s?/i);
+  if (!m) return 0;
+  const count = /^\d+$/.test(m[1]) ? parseInt(m[1], 10) : /less/i.test(m[1]) ? 0 : 1;
+  return count * DURATION_UNITS[m[2].toLowerCase()];
+}
+
+// "Up 3 hours (healthy)" / "Exited (137) 5 minutes ago" / "Created" to { running, sinceMs, exitCode }
+function parseStatus(status) {
+  const str = status || "";
+  const running = /^Up\b/.test(str);
+  const exitCode = str.match(/^(?:Exited|Restarting) \((-?\d+)\)/)?.[1];
+  return { running, sinceMs: parseDuration(str), exitCode: exitCode === undefined ? null : parseInt(exitCode, 10) };
+}
+
+module.exports = { parseDockerSize, parseDuration, parseStatus };
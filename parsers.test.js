@@ -0,0 +1,39 @@
+import { test, expect } from "bun:test";
+import { parseDockerSize, parseDuration, parseStatus } from "./parsers";
+
+test("parseDockerSize handles decimal units", () => {
+  expect(parseDockerSize("12.5kB")).toBe(12500);
+  expect(parseDockerSize("900MB")).toBe(900e6);
+  expect(parseDockerSize("1.2GB")).toBe(1.2e9);
+  expect(parseDockerSize("0B")).toBe(0);
+});
+
+test("parseDockerSize handles binary units", () => {
+  expect(parseDockerSize("1.5GiB")).toBe(1.5 * 1024 ** 3);
+  expect(parseDockerSize("256MiB")).toBe(256 * 1024 ** 2);
+  expect(parseDockerSize(" 4 KiB ")).toBe(4096);
+});
+
+test("parseDockerSize returns 0 for unparseable input", () => {
+  expect(parseDockerSize("")).toBe(0);
+  expect(parseDockerSize(undefined)).toBe(0);
+  expect(parseDockerSize("N/A")).toBe(0);
+  expect(parseDockerSize("12 parsecs")).toBe(0);
+  expect(parseDockerSize("1.2XB")).toBe(0);
+});
+
+test("parseDuration reads counts and approximate phrases", () => {
+  expect(parseDuration("5 minutes")).toBe(5 * 6e4);
+  expect(parseDuration("About an hour")).toBe(36e5);
+  expect(parseDuration("a day")).toBe(864e5);
+  expect(parseDuration("Less than a second")).toBe(0);
+  expect(parseDuration("Created")).toBe(0);
+});
+
+test("parseStatus reads running, uptime and exit code", () => {
+  expect(parseStatus("Up 3 hours (healthy)")).toEqual({ running: true, sinceMs: 3 * 36e5, exitCode: null });
+  expect(parseStatus("Exited (137) 5 minutes ago")).toEqual({ running: false, sinceMs: 5 * 6e4, exitCode: 137 });
+  expect(parseStatus("Up About an hour")).toEqual({ running: true, sinceMs: 36e5, exitCode: null });
+  expect(parseStatus("Created")).toEqual({ running: false, sinceMs: 0, exitCode: null });
+  expect(parseStatus(undefined)).toEqual({ running: false, sinceMs: 0, exitCode: null });
+});
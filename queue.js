@@ -0,0 +1,16 @@
+// Serial operation queue: each operation starts only after the previous one has settled.
+// A failed operation doesn't stop the queue; hooks report progress and errors to the caller.
+function createOperationQueue({ onQueued, onError, onSettled } = {}) {
+  let tail = Promise.resolve();
+
+  return function enqueue(label, fn) {
+    onQueued?.(label);
+    const run = tail.then(fn).catch(error => {
+      onError?.(label, error, () => enqueue(label, fn));
+    }).finally(() => onSettled?.(label));
+    tail = run;
+    return run;
+  };
+}
+
+module.exports = { createOperationQueue };
@@ -0,0 +1,74 @@
+import { test, expect } from "bun:test";
+import { createOperationQueue } from "./queue";
+
+const sleep = ms => new Promise(resolve => setTimeout(resolve, ms));
+
+test("operations run one at a time, in the order they were queued", async () => {
+  const enqueue = createOperationQueue();
+  const events = [];
+  const op = (name, ms) => async () => {
+    events.push(`${name} start`);
+    await sleep(ms);
+    events.push(`${name} end`);
+  };
+  await Promise.all([enqueue("a", op("a", 20)), enqueue("b", op("b", 1)), enqueue("c", op("c", 5))]);
+  expect(events).toEqual(["a start", "a end", "b start", "b end", "c start", "c end"]);
+});
+
+test("an operation can await a refresh while the next operation waits its turn", async () => {
+  const enqueue = createOperationQueue();
+  const events = [];
+  // Reads like updateAll don't go through the queue, so awaiting one inside an operation is safe
+  const refresh = async () => {
+    await sleep(10);
+    events.push("refresh");
+  };
+  const first = enqueue("delete", async () => {
+    events.push("delete start");
+    await refresh();
+    events.push("delete end");
+  });
+  const second = enqueue("start", async () => events.push("start"));
+  await Promise.all([first, second]);
+  expect(events).toEqual(["delete start", "refresh", "delete end", "start"]);
+});
+
+test("an operation fired from inside another runs after it instead of nesting", async () => {
+  const enqueue = createOperationQueue();
+  const events = [];
+  let inner;
+  await enqueue("outer", async () => {
+    events.push("outer start");
+    inner = enqueue("inner", async () => events.push("inner"));
+    await sleep(5);
+    events.push("outer end");
+  });
+  await inner;
+  expect(events).toEqual(["outer start", "outer end", "inner"]);
+});
+
+test("a failed operation is reported and doesn't stop the queue", async () => {
+  const errors = [];
+  const settled = [];
+  const enqueue = createOperationQueue({
+    onError: (label, error) => errors.push(`${label}: ${error.message}`),
+    onSettled: label => settled.push(label),
+  });
+  const ran = [];
+  enqueue("bad", async () => { throw new Error("boom"); });
+  await enqueue("good", async () => ran.push("good"));
+  expect(errors).toEqual(["bad: boom"]);
+  expect(ran).toEqual(["good"]);
+  expect(settled).toEqual(["bad", "good"]);
+});
+
+test("the retry passed to onError queues the operation again", async () => {
+  let attempts = 0;
+  let retried;
+  const enqueue = createOperationQueue({ onError: (label, error, retry) => { retried = retry(); } });
+  await enqueue("flaky", async () => {
+    if (++attempts === 1) throw new Error("first try");
+  });
+  await retried;
+  expect(attempts).toBe(2);
+});